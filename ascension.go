@@ -0,0 +1,191 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io/fs"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/loig/ebitenginegamejam2024/assets"
+)
+
+// Each win of a standard run unlocks the next tier of difficulty,
+// tier 0 is the normal game and tier i (from 1) uses gRules.Tiers[i-1].
+// The tier is chosen on the title screen, with left and right when
+// play is selected, and the best scores are kept for each tier.
+
+// number of best scores kept for each tier
+const maxHighScores int = 10
+
+// height of the rows of assets.ImageTier
+const tierLabelHeight int = 50
+
+type highScore struct {
+	Score int  `json:"score"`
+	Level int  `json:"level"` // level reached, from 1
+	Tier  int  `json:"tier"`
+	Won   bool `json:"won"`
+}
+
+// what is kept from one launch of the game to the next
+type progress struct {
+	MaxTier int         `json:"maxTier"` // highest tier unlocked
+	Scores  []highScore `json:"scores"`  // best scores of all tiers, from the best
+}
+
+// read the progress from a file, a missing file is a game never played
+func loadProgress(path string) (p progress, err error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return p, fmt.Errorf("progress: %w", err)
+	}
+	if err = json.Unmarshal(content, &p); err != nil {
+		return p, fmt.Errorf("progress %s: %w", path, err)
+	}
+	// the rules may have less tiers than when the progress was saved
+	p.MaxTier = min(max(p.MaxTier, 0), len(gRules.Tiers))
+	return p, nil
+}
+
+func (p progress) save(path string) error {
+	content, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("progress: %w", err)
+	}
+	if err = os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("progress: %w", err)
+	}
+	return nil
+}
+
+// add a score, keeping only the best ones of its tier
+func (p *progress) addScore(s highScore) {
+	p.Scores = append(p.Scores, s)
+	sort.SliceStable(p.Scores, func(i, j int) bool {
+		return p.Scores[i].Score > p.Scores[j].Score
+	})
+	kept := p.Scores[:0]
+	count := 0
+	for _, other := range p.Scores {
+		if other.Tier == s.Tier {
+			count++
+			if count > maxHighScores {
+				continue
+			}
+		}
+		kept = append(kept, other)
+	}
+	p.Scores = kept
+}
+
+// best score of a tier, if any
+func (p progress) best(tier int) (s highScore, found bool) {
+	for _, s := range p.Scores {
+		if s.Tier == tier {
+			return s, true
+		}
+	}
+	return s, false
+}
+
+// changes of the rules for the current tier
+func (g game) tierRules() rulesTier {
+	if g.tier <= 0 || g.tier > len(gRules.Tiers) {
+		return rulesTier{}
+	}
+	return gRules.Tiers[g.tier-1]
+}
+
+// level to reach for winning in the current tier, 0 for none
+func (g game) runGoalLevel() int {
+	if g.goalLevel == 0 {
+		return 0
+	}
+	return g.goalLevel + g.tierRules().GoalLevel
+}
+
+// choose the tier of the next runs among the unlocked ones
+func (g *game) selectTier(step int) (changed bool) {
+	tier := min(max(g.tier+step, 0), g.progress.MaxTier)
+	changed = tier != g.tier
+	g.tier = tier
+	return
+}
+
+// record the end of a run, a win unlocks the next tier
+func (g *game) endRun(won bool) {
+	if g.mode != modeStandard || g.replaying != nil || g.cheated {
+		return
+	}
+	g.progress.addScore(highScore{
+		Score: g.currentPlay.score,
+		Level: g.level + 1,
+		Tier:  g.tier,
+		Won:   won,
+	})
+	if won && g.tier == g.progress.MaxTier && g.progress.MaxTier < len(gRules.Tiers) {
+		g.progress.MaxTier++
+	}
+	if g.progressPath == "" {
+		return
+	}
+	if err := g.progress.save(g.progressPath); err != nil {
+		log.Print(err)
+	}
+}
+
+// show the tier chosen and its best score on the title screen, on
+// the left of the menu, once a tier is unlocked
+func (g game) drawTier(screen *ebiten.Image) {
+
+	if g.progress.MaxTier == 0 {
+		return
+	}
+
+	const (
+		x       int     = 60
+		right   int     = 420
+		scaling float64 = 0.5
+	)
+	digitHeight := int(float64(gSquareSideSize) * scaling)
+	rows := [2]int{3*gHeight/4 + 50, 3*gHeight/4 + 162} // middle of play and credits
+
+	options := ebiten.DrawImageOptions{}
+	options.GeoM.Translate(float64(x), float64(rows[0]-tierLabelHeight/2))
+	screen.DrawImage(assets.ImageTier.SubImage(image.Rect(0, 0, assets.ImageTier.Bounds().Dx(), tierLabelHeight)).(*ebiten.Image), &options)
+	drawScaledNumberAt(screen, 255, right, rows[0]-digitHeight/2, g.tier, len(gRules.Tiers), scaling)
+
+	best, found := g.progress.best(g.tier)
+	if !found {
+		return
+	}
+	options.GeoM.Translate(0, float64(rows[1]-rows[0]))
+	screen.DrawImage(assets.ImageTier.SubImage(image.Rect(0, tierLabelHeight, assets.ImageTier.Bounds().Dx(), 2*tierLabelHeight)).(*ebiten.Image), &options)
+	drawScaledNumberAt(screen, 255, right, rows[1]-digitHeight/2, best.Score, -1, scaling)
+}
@@ -144,6 +144,22 @@ var ImageWin *ebiten.Image
 var imageRocketBytes []byte
 var ImageRocket *ebiten.Image
 
+//go:embed bonuspreview.png
+var imageBonusPreviewBytes []byte
+var ImageBonusPreview *ebiten.Image
+
+//go:embed cleanse.png
+var imageCleanseBytes []byte
+var ImageCleanse *ebiten.Image
+
+//go:embed tier.png
+var imageTierBytes []byte
+var ImageTier *ebiten.Image
+
+//go:embed slow.png
+var imageSlowBytes []byte
+var ImageSlow *ebiten.Image
+
 func Load(mult int) {
 	var err error
 
@@ -326,6 +342,30 @@ func Load(mult int) {
 		log.Fatal(err)
 	}
 	ImageRocket = ebiten.NewImageFromImage(imageDecoded)
+
+	imageDecoded, _, err = image.Decode(bytes.NewReader(imageBonusPreviewBytes))
+	if err != nil {
+		log.Fatal(err)
+	}
+	ImageBonusPreview = ebiten.NewImageFromImage(imageDecoded)
+
+	imageDecoded, _, err = image.Decode(bytes.NewReader(imageCleanseBytes))
+	if err != nil {
+		log.Fatal(err)
+	}
+	ImageCleanse = ebiten.NewImageFromImage(imageDecoded)
+
+	imageDecoded, _, err = image.Decode(bytes.NewReader(imageTierBytes))
+	if err != nil {
+		log.Fatal(err)
+	}
+	ImageTier = ebiten.NewImageFromImage(imageDecoded)
+
+	imageDecoded, _, err = image.Decode(bytes.NewReader(imageSlowBytes))
+	if err != nil {
+		log.Fatal(err)
+	}
+	ImageSlow = ebiten.NewImageFromImage(imageDecoded)
 }
 
 func resize(img *ebiten.Image, mult int) (res *ebiten.Image) {
@@ -103,10 +103,15 @@ type SoundManager struct {
 	audioContext *audio.Context
 	NextSounds   [NumSounds]bool
 	music        *audio.Player
+	Muted        bool
 }
 
 // loop the music
 func (s *SoundManager) UpdateMusic(volume float64) {
+	if s.Muted {
+		s.StopMusic()
+		return
+	}
 	if s.music != nil {
 		if !s.music.IsPlaying() {
 			s.music.Rewind()
@@ -125,6 +130,9 @@ func (s *SoundManager) StopMusic() {
 
 // play requested sounds
 func (s SoundManager) PlaySounds() {
+	if s.Muted {
+		return
+	}
 	for sound, play := range s.NextSounds {
 		if play {
 			s.playSound(sound)
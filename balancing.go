@@ -22,42 +22,67 @@ import (
 	"image"
 	"image/color"
 	"math"
-	"math/rand"
 
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/loig/ebitenginegamejam2024/assets"
 )
 
+// entries of balancing.choices that are not maluses
 const (
-	balanceGoalLines int = iota
-	balanceSpeed
-	balanceHiddenLines
-	balanceDeathLines
-	balanceInvisibleBlocks
-	numBalances
+	choiceSkip    int = -2 // skip token
+	choiceCleanse int = -3 // lower the level of balancing.cleanseTarget
 )
 
-const (
-	maxLevelGoalLines       = 2
-	maxLevelSpeed           = 5
-	maxLevelHiddenLines     = 5
-	maxLevelDeathLines      = 5
-	maxLevelInvisibleBlocks = 3
-)
+// places kept at the end of balancing.choices for the entries that are not maluses
+const numSpecialChoices int = 2
+
+// frames of the animation of a malus level going down
+const cleanseNumFrames int = 60
 
 type balancing struct {
-	levels          [numBalances]int
-	maxLevels       [numBalances]int
+	levels          []int // level of each malus of gMaluses
 	choice          int
 	choiceDirection int
-	choices         []int
+	choices         []int // maluses offered, the last places are kept for the cleansing and the skip token
 	numChoices      int
 	inTransition    bool
 	transitionFrame int
+	skipChance      int     // percent chance of offering a skip token
+	freeSkip        bool    // the skip token costs nothing as nothing else could be offered
+	sinceOffered    []int   // number of offers since each malus was last offered
+	bonuses         []bonus // bonus paired with each choice
+	taken           bonus   // bonus of the last choice made
+	frame           int
+	cleanseTarget   int  // malus lowered by choiceCleanse
+	cleanseForced   bool // choiceCleanse is offered only because of forceCleanse
+	cleansing       int  // malus whose level is going down in the animation
+	cleanseFrame    int  // frames left in the animation
+	cleanseThenEnd  bool // the choice ends with the animation
+	extraGoalLines  int  // lines added to the goal of each level by the tier
+	extraDeathLines int  // lines added to the danger zone by the tier
 }
 
-func (b *balancing) update() (end bool, playSounds [assets.NumSounds]bool) {
+// weight of the maluses not given in rules.Offers.Weights
+const defaultOfferWeight int = 100
+
+func (b *balancing) update(input inputState) (end bool, playSounds [assets.NumSounds]bool) {
+
+	b.frame++
+
+	if b.cleanseFrame > 0 {
+		b.cleanseFrame--
+		if b.cleanseFrame == 0 {
+			end = b.cleanseThenEnd
+			b.cleanseThenEnd = false
+		}
+		return
+	}
+
+	if b.numChoices == 0 {
+		// nothing to choose, confirming ends the balancing
+		end = input.isJustPressed(inputEnter)
+		return
+	}
 
 	if b.inTransition {
 		b.transitionFrame++
@@ -73,30 +98,60 @@ func (b *balancing) update() (end bool, playSounds [assets.NumSounds]bool) {
 		return
 	}
 
-	if inpututil.IsKeyJustPressed(ebiten.KeyLeft) {
+	if input.isJustPressed(inputLeft) {
 		playSounds[assets.SoundMenuMoveID] = true
 		b.choiceDirection = 1
 		b.inTransition = true
 	}
 
-	if inpututil.IsKeyJustPressed(ebiten.KeyRight) {
+	if input.isJustPressed(inputRight) {
 		playSounds[assets.SoundMenuMoveID] = true
 		b.choiceDirection = -1
 		b.inTransition = true
 	}
 
-	end = inpututil.IsKeyJustPressed(ebiten.KeyEnter)
+	end = input.isJustPressed(inputEnter)
 
 	if end {
+		b.taken = b.bonuses[b.choice]
 		b.setChoice(b.choices[b.choice])
 		b.choice = 0
 		playSounds[assets.SoundMenuConfirmID] = true
+		if b.cleanseFrame > 0 {
+			b.cleanseThenEnd = true
+			end = false
+		}
 	}
 
 	return
 }
 
-func drawLevel(screen *ebiten.Image, level, levelMax int, x, y float64) {
+// check if the choice cannot be changed for now
+func (b balancing) busy() bool {
+	return b.inTransition || b.cleanseFrame > 0
+}
+
+// check if the current choice is the skip token
+func (b balancing) skipSelected() bool {
+	return b.choices[b.choice] == choiceSkip
+}
+
+// coins needed for using the skip token
+func (b balancing) skipCost() int {
+	if b.freeSkip {
+		return 0
+	}
+	return gRules.SkipCost
+}
+
+// check if the current choice is the cleansing of a malus
+func (b balancing) cleanseSelected() bool {
+	return b.choices[b.choice] == choiceCleanse
+}
+
+// the level after level is drawn with the opacity given by fading,
+// for showing a level going down
+func drawLevel(screen *ebiten.Image, level, levelMax int, x, y float64, fading float64) {
 
 	factor := 0.7
 	size := factor * float64(gChoiceLevelSize)
@@ -131,10 +186,15 @@ func drawLevel(screen *ebiten.Image, level, levelMax int, x, y float64) {
 			shift = 1
 		}
 		screen.DrawImage(assets.ImageLevel.SubImage(image.Rect(shift*gChoiceLevelSize, 0, (shift+1)*gChoiceLevelSize, gChoiceLevelSize)).(*ebiten.Image), &options)
+		if i == level+1 && fading > 0 {
+			fade := options
+			fade.ColorScale.ScaleAlpha(float32(fading))
+			screen.DrawImage(assets.ImageLevel.SubImage(image.Rect(0, 0, gChoiceLevelSize, gChoiceLevelSize)).(*ebiten.Image), &fade)
+		}
 	}
 }
 
-func (b balancing) drawChoices(screen *ebiten.Image, cX, cY int) {
+func (b balancing) drawChoices(screen *ebiten.Image, cX, cY, nextLevel int) {
 
 	r := float64(gHeight / 7)
 	var gray uint8 = 200
@@ -156,16 +216,14 @@ func (b balancing) drawChoices(screen *ebiten.Image, cX, cY int) {
 	options.ColorScale.ScaleWithColor(color.Gray{currentGray})
 	options.GeoM.Translate(currentX, currentY)
 	if !b.inTransition {
-		screen.DrawImage(assets.ImageMalus.SubImage(image.Rect(numBalances*gChoiceSize, 0, (numBalances+1)*gChoiceSize, gChoiceSize)).(*ebiten.Image), &options)
+		screen.DrawImage(malusIcon(malusSelectedIcon), &options)
 	}
-	screen.DrawImage(assets.ImageMalus.SubImage(image.Rect(b.choices[b.choice]*gChoiceSize, 0, (b.choices[b.choice]+1)*gChoiceSize, gChoiceSize)).(*ebiten.Image), &options)
-	drawLevel(screen, b.levels[b.choices[b.choice]], b.maxLevels[b.choices[b.choice]], currentX, currentY)
+	b.drawChoice(screen, b.choice, currentX, currentY, nextLevel, &options)
 
 	// other choices
 	for i := 0; i < b.numChoices-1; i++ {
 		// find the choice to display
 		displayNum := (b.choice + i + 1) % b.numChoices
-		theChoice := b.choices[displayNum]
 
 		//find the position to display it
 		angle := float64(i+1)*(math.Pi*2)/float64(b.numChoices) + math.Pi/2 + angleShift
@@ -176,78 +234,263 @@ func (b balancing) drawChoices(screen *ebiten.Image, cX, cY int) {
 		options := ebiten.DrawImageOptions{}
 		options.ColorScale.ScaleWithColor(color.Gray{gray})
 		options.GeoM.Translate(x, y)
-		screen.DrawImage(assets.ImageMalus.SubImage(image.Rect(theChoice*gChoiceSize, 0, (theChoice+1)*gChoiceSize, gChoiceSize)).(*ebiten.Image), &options)
-		drawLevel(screen, b.levels[theChoice], b.maxLevels[theChoice], x, y)
+		b.drawChoice(screen, displayNum, x, y, nextLevel, &options)
 	}
 
 }
 
-func (b balancing) draw(screen *ebiten.Image) {
+// draw the icon of the choice at position num in b.choices with its level
+// and its bonus, or with its cost for the skip token
+func (b balancing) drawChoice(screen *ebiten.Image, num int, x, y float64, nextLevel int, options *ebiten.DrawImageOptions) {
+	choice := b.choices[num]
+
+	gray := uint8(255)
+	if num != b.choice || b.inTransition {
+		gray = 200
+	}
+
+	if choice == choiceCleanse {
+		// the level that would be removed blinks
+		blink := 0.0
+		if (b.frame/15)%2 == 0 {
+			blink = 1
+		}
+		screen.DrawImage(gMaluses[b.cleanseTarget].Icon(), options)
+		drawLevel(screen, b.levels[b.cleanseTarget]-2, gMaluses[b.cleanseTarget].MaxLevel(), x, y, blink)
+		drawBadge(screen, assets.ImageCleanse, x+float64(gChoiceSize)-bonusSize/2-20, y+bonusSize/2+20, gray)
+		return
+	}
+
+	if choice == choiceSkip {
+		screen.DrawImage(malusIcon(malusSkipIcon), options)
+		drawMoney(screen, int(x)+gChoiceSize/2, int(y)+gChoiceSize-80, b.skipCost(), false, 0.4)
+		return
+	}
+	screen.DrawImage(gMaluses[choice].Icon(), options)
+	drawLevel(screen, b.levels[choice], gMaluses[choice].MaxLevel(), x, y, 0)
+	b.drawChoiceValues(screen, choice, nextLevel, int(x)+gChoiceSize/2, int(y)+gChoiceSize-10, gray)
+
+	b.bonuses[num].draw(screen, x+float64(gChoiceSize)-bonusSize/2-20, y+bonusSize/2+20, gray)
+}
+
+// values of a malus for the next level, without and with choosing
+// it, computed by applying all the maluses on scratch games, there
+// are none to show if the malus has no effect before choosing it
+func (b balancing) choiceValues(choice, nextLevel int) (before, after int, ok bool) {
+
+	if choice < 0 {
+		return 0, 0, false
+	}
+
+	m, ok := gMaluses[choice].(valuedMalus)
+	if !ok {
+		return 0, 0, false
+	}
+
+	t := tetris{speedLevel: nextLevel}
+	b.apply(&t)
+	if before, ok = m.Value(t); !ok {
+		return 0, 0, false
+	}
+
+	b.levels = append([]int{}, b.levels...)
+	b.levels[choice]++
+	t = tetris{speedLevel: nextLevel}
+	b.apply(&t)
+	after, _ = m.Value(t)
+
+	return before, after, true
+}
+
+// show how a choice changes the game, as a small "before > after"
+// centered on x, under the icon of the choice
+func (b balancing) drawChoiceValues(screen *ebiten.Image, choice, nextLevel, x, y int, gray uint8) {
+
+	before, after, ok := b.choiceValues(choice, nextLevel)
+	if !ok {
+		return
+	}
+
+	digits := func(num int) (n int) {
+		for n = 1; num >= 10; n++ {
+			num /= 10
+		}
+		return
+	}
+
+	scaling := 0.5
+	step := int(float64(gSquareSideSize) * scaling)
+	arrow := int(float64(gArrowHeight) * scaling)
+	space := step / 3
+
+	width := digits(before)*step + space + arrow + space + digits(after)*step
+	x -= width / 2
+
+	x += digits(before) * step
+	drawScaledNumberAt(screen, gray, x, y, before, 0, scaling)
+
+	x += space + arrow
+	drawScaledArrow(screen, x, y+(step-int(float64(gArrowWidth)*scaling))/2, math.Pi/2, 0, scaling)
+
+	x += space + digits(after)*step
+	drawScaledNumberAt(screen, gray, x, y, after, 0, scaling)
+}
+
+// nextLevel is the level that will be played after the choice
+func (b balancing) draw(screen *ebiten.Image, nextLevel int) {
 
 	options := ebiten.DrawImageOptions{}
 	options.GeoM.Translate(float64(gWidth-gLevelCompleteWidth)/2, float64(gTitleMargin))
 	screen.DrawImage(assets.ImageLevelComplete, &options)
 
-	b.drawChoices(screen, gWidth/2, gHeight/2-30)
-
 	options = ebiten.DrawImageOptions{}
 	options.GeoM.Translate(float64(gWidth-gTextMalusWidth)/2, float64(gHeight-gTextMalusHeight))
-	id := b.choices[b.choice]
-	screen.DrawImage(assets.ImageTextMalus.SubImage(image.Rect(0, id*gTextMalusHeight, gTextMalusWidth, (id+1)*gTextMalusHeight)).(*ebiten.Image), &options)
+
+	if b.cleanseFrame > 0 {
+		b.drawCleansing(screen, gWidth/2, gHeight/2-30)
+		screen.DrawImage(malusDescription(malusCleanseDescription), &options)
+		return
+	}
+
+	if b.numChoices == 0 {
+		return
+	}
+
+	b.drawChoices(screen, gWidth/2, gHeight/2-30, nextLevel)
+
+	current := b.bonuses[b.choice]
+	if b.cleanseSelected() {
+		screen.DrawImage(malusDescription(malusCleanseDescription), &options)
+	} else if b.skipSelected() {
+		screen.DrawImage(malusDescription(malusSkipDescription), &options)
+	} else if current.kind != bonusNone && (b.frame/bonusDescriptionFrames)%2 == 1 {
+		screen.DrawImage(malusDescription(gBonusDescriptions[current.kind]), &options)
+	} else {
+		screen.DrawImage(gMaluses[b.choices[b.choice]].Description(), &options)
+	}
+}
+
+// draw the malus being cleansed where the current choice is, with its level going down
+func (b balancing) drawCleansing(screen *ebiten.Image, cX, cY int) {
+
+	r := float64(gHeight / 7)
+	x := float64(cX - gChoiceSize/2)
+	y := float64(cY-gChoiceSize/2) - r
+
+	m := gMaluses[b.cleansing]
+	options := ebiten.DrawImageOptions{}
+	options.GeoM.Translate(x, y)
+	screen.DrawImage(m.Icon(), &options)
+	drawLevel(screen, b.levels[b.cleansing]-1, m.MaxLevel(), x, y, float64(b.cleanseFrame)/float64(cleanseNumFrames))
+	drawBadge(screen, assets.ImageCleanse, x+float64(gChoiceSize)-bonusSize/2-20, y+bonusSize/2+20, 255)
 }
 
-func newBalance(numChoices int) balancing {
+// skipLevel is the level of the skip improvement
+func newBalance(numChoices, skipLevel int) balancing {
 
 	b := balancing{}
 
-	b.choices = make([]int, numChoices)
+	b.setSkipLevel(skipLevel)
+
+	b.choices = make([]int, numChoices+numSpecialChoices)
+	b.bonuses = make([]bonus, numChoices+numSpecialChoices)
 	for i := range b.choices {
 		b.choices[i] = -1
 	}
 
-	b.maxLevels[balanceGoalLines] = maxLevelGoalLines
-	b.maxLevels[balanceSpeed] = maxLevelSpeed
-	b.maxLevels[balanceHiddenLines] = maxLevelHiddenLines
-	b.maxLevels[balanceDeathLines] = maxLevelDeathLines
-	b.maxLevels[balanceInvisibleBlocks] = maxLevelInvisibleBlocks
+	b.levels = make([]int, len(gMaluses))
+	b.sinceOffered = make([]int, len(gMaluses))
+
 	return b
 }
 
-func (b *balancing) getChoice() {
+// start from the malus levels and the goal lines of a tier, life is the
+// number of lives of the run without the tier, each heart of the tier
+// that cannot be removed from it adds a line to the danger zone
+func (b *balancing) setTier(tier rulesTier, life int) {
+	for id, level := range tier.Maluses {
+		if pos, found := findMalus(id); found {
+			b.levels[pos] = level
+		}
+	}
+	b.extraGoalLines = tier.GoalLines
+	b.extraDeathLines = max(tier.Hearts-max(life, 0), 0)
+}
 
-	possibleChoices := make([]int, 0, 2*numBalances)
+// set the chance of offering a skip token from the level of the skip improvement
+func (b *balancing) setSkipLevel(level int) {
+	b.skipChance = 0
+	if level > 0 {
+		b.skipChance = ruleAt(gRules.SkipChances, level-1)
+	}
+}
 
-BalanceLoop:
-	for c := 0; c < numBalances; c++ {
-		if b.levels[c] < b.maxLevels[c] {
-			possibleChoices = append(possibleChoices, c)
-			for _, oldChoice := range b.choices {
-				if c == oldChoice {
-					continue BalanceLoop
-				}
-			}
-			possibleChoices = append(possibleChoices, c)
+// draw the maluses offered after a level (0 for the first offer),
+// using the weights given by the rules, a cleansing is always
+// offered with forceCleanse if a malus can be cleansed, and a free
+// skip token is offered when nothing else can be
+func (b *balancing) getChoice(level int, forceCleanse bool) {
+
+	weights := make([]int, len(gMaluses))
+	available := 0
+	for id := range gMaluses {
+		weights[id] = b.offerWeight(id, level)
+		if weights[id] > 0 {
+			available++
 		}
 	}
 
+	for i := range b.bonuses {
+		b.bonuses[i] = bonus{}
+	}
+
+	numMaluses := min(available, len(b.choices)-numSpecialChoices)
+	fresh := false
+
 	choice := 0
-	for ; len(possibleChoices) > 0 && choice < len(b.choices); choice++ {
-		take := rand.Intn(len(possibleChoices))
-		b.choices[choice] = possibleChoices[take]
-
-		possibleChoices = removeElement(possibleChoices, take)
-
-		found := true
-		for found {
-			found = false
-			for i := range possibleChoices {
-				if possibleChoices[i] == b.choices[choice] {
-					possibleChoices = removeElement(possibleChoices, i)
-					found = true
-					break
+	for ; choice < numMaluses; choice++ {
+		draw := weights
+		if choice == numMaluses-1 && !fresh {
+			// the last place goes to a malus that is not near-maxed, if any
+			restricted := make([]int, len(weights))
+			total := 0
+			for id, w := range weights {
+				if !b.nearMaxed(id) {
+					restricted[id] = w
+					total += w
 				}
 			}
+			if total > 0 {
+				draw = restricted
+			}
 		}
+		id := weightedChoice(draw, len(draw))
+		b.choices[choice] = id
+		weights[id] = 0
+		fresh = fresh || !b.nearMaxed(id)
+	}
+
+	for id := range b.sinceOffered {
+		b.sinceOffered[id]++
+	}
+	for _, id := range b.choices[:choice] {
+		b.sinceOffered[id] = 0
+	}
+
+	b.cleanseForced = false
+	if lucky := gRandom.Intn(100) < gRules.CleanseChance; forceCleanse || lucky {
+		if b.cleanseTarget = b.randomActive(); b.cleanseTarget >= 0 {
+			b.choices[choice] = choiceCleanse
+			b.cleanseForced = !lucky
+			choice++
+		}
+	}
+
+	// with nothing else to choose, the skip token must always be usable
+	b.freeSkip = choice == 0
+	if b.freeSkip || (b.skipChance > 0 && gRandom.Intn(100) < b.skipChance) {
+		b.choices[choice] = choiceSkip
+		choice++
 	}
 
 	b.numChoices = choice
@@ -258,61 +501,84 @@ BalanceLoop:
 
 }
 
-func (b *balancing) setChoice(choice int) {
-	b.levels[choice]++
-}
+// weight of a malus for the offer made after a level, it is the
+// base weight of the malus, scaled by its level curve, reduced if
+// it was offered the previous time, and increased by the pity timer
+func (b balancing) offerWeight(id, level int) int {
 
-func (b balancing) getDeathLines() (numLines int) {
-	const maxDeathLines int = 2*gPlayAreaHeightInBlocks/3 - 1
-
-	numLines = 2*b.levels[balanceDeathLines] + 1
-	if numLines > maxDeathLines {
-		numLines = maxDeathLines
+	m := gMaluses[id]
+	if b.levels[id] >= m.MaxLevel() {
+		return 0
 	}
-	return
-}
 
-func (b balancing) getHiddenLines() (numLines int) {
-	const hiddenFactor int = 3
-	const maxHiddenLines int = 15
+	offers := gRules.Offers
 
-	numLines = hiddenFactor * b.levels[balanceHiddenLines]
-	if numLines > maxHiddenLines {
-		numLines = maxHiddenLines
+	weight := defaultOfferWeight
+	if w, ok := offers.Weights[m.ID()]; ok {
+		weight = w
 	}
 
-	return
-}
+	if curve, ok := offers.LevelCurves[m.ID()]; ok {
+		weight = weight * ruleAt(curve, level) / 100
+	}
 
-func (b balancing) getGoalLines() int {
-	var goalLines [maxLevelGoalLines + 1]int = [maxLevelGoalLines + 1]int{
-		4, 8, 12,
+	for _, previous := range b.choices {
+		if previous == id {
+			weight = weight * offers.Repeat / 100
+			break
+		}
 	}
 
-	if b.levels[balanceGoalLines] < len(goalLines) {
-		return goalLines[b.levels[balanceGoalLines]]
+	if missed := b.sinceOffered[id] - offers.Pity; offers.Pity > 0 && missed >= 0 {
+		weight = weight * (100 + offers.PityGrowth*(missed+1)) / 100
 	}
-	return goalLines[len(goalLines)-1]
+
+	return weight
 }
 
-func (b balancing) getSpeedLevel(baseSpeedLevel int) int {
-	var speedLevels [maxLevelSpeed]int = [maxLevelSpeed]int{
-		1, 2, 4, 7, 10,
-	}
+// check if a malus is close to its max level
+func (b balancing) nearMaxed(id int) bool {
+	return b.levels[id] >= gMaluses[id].MaxLevel()-gRules.Offers.NearMaxed
+}
 
-	id := b.levels[balanceSpeed]
-	if id >= len(speedLevels) {
-		id = len(speedLevels) - 1
+func (b *balancing) setChoice(choice int) {
+	switch choice {
+	case choiceSkip:
+	case choiceCleanse:
+		b.cleanse(b.cleanseTarget)
+	default:
+		if choice >= 0 {
+			b.levels[choice]++
+		}
 	}
-	baseSpeedLevel += speedLevels[id]
+}
 
-	if baseSpeedLevel >= gSpeedLevels {
-		baseSpeedLevel = gSpeedLevels - 1
-	}
+// lower the level of a malus, with an animation
+func (b *balancing) cleanse(id int) {
+	b.levels[id]--
+	b.cleansing = id
+	b.cleanseFrame = cleanseNumFrames
+}
 
-	return baseSpeedLevel
+// a random malus with a level above 0, -1 if there is none
+func (b balancing) randomActive() int {
+	var active []int
+	for id, level := range b.levels {
+		if level > 0 {
+			active = append(active, id)
+		}
+	}
+	if len(active) == 0 {
+		return -1
+	}
+	return active[gRandom.Intn(len(active))]
 }
 
-func (b balancing) getInvisibleBlocks() int {
-	return b.levels[balanceInvisibleBlocks]
+// set the effects of all the maluses on a game
+func (b balancing) apply(t *tetris) {
+	for id, m := range gMaluses {
+		m.Apply(t, b.levels[id])
+	}
+	t.goalLines += b.extraGoalLines
+	t.deathLines = min(t.deathLines+b.extraDeathLines, maxDeathLines)
 }
@@ -0,0 +1,89 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/loig/ebitenginegamejam2024/assets"
+)
+
+const (
+	biasBarHeight int     = 20 // height of a line of the histogram in pixels
+	biasBarWidth  float32 = 36 // width of the longest bar of the histogram in pixels
+)
+
+// weights for choosing the next piece, nil if it should be uniform
+func (t tetris) blockWeights() []int {
+	if t.biasLevel <= 0 {
+		return nil
+	}
+	level := min(t.biasLevel, len(gRules.BiasWeights)) - 1
+	weights := append([]int{}, gRules.BiasWeights[level]...)
+	if t.piecesSinceI < ruleAt(gRules.BiasDroughts, t.biasLevel-1) {
+		weights[0] = 0
+	}
+	return weights
+}
+
+// get a new piece following the bad luck malus, and count it
+func (t *tetris) getNewBlock(current, next tetrisBlock) tetrisBlock {
+	block := getNewBlock(current, next, t.blockWeights())
+	t.piecesSinceI++
+	if block.style == iBlockStyle {
+		t.piecesSinceI = 0
+	}
+	t.pieceCounts[block.style]++
+	return block
+}
+
+// histogram of the pieces generated since the start of the
+// run, in the left border of the play area
+func (t tetris) drawBias(screen *ebiten.Image, gray uint8) {
+	if t.biasLevel <= 0 {
+		return
+	}
+
+	maxCount := 1
+	for _, count := range t.pieceCounts {
+		maxCount = max(maxCount, count)
+	}
+
+	scaling := float64(biasBarHeight-4) / float64(gSquareSideSize)
+	x := 4
+	y := 2 * gPlayAreaSide
+
+	barColor := grayColor(color.RGBA{224, 248, 208, 255}, gray)
+
+	for style := iBlockStyle; style <= zBlockStyle; style++ {
+		options := ebiten.DrawImageOptions{}
+		options.ColorScale.ScaleWithColor(color.Gray{gray})
+		options.GeoM.Scale(scaling, scaling)
+		options.GeoM.Translate(float64(x), float64(y+2))
+		screen.DrawImage(assets.ImageSquares.SubImage(image.Rect((style-1)*gSquareSideSize, 0, style*gSquareSideSize, gSquareSideSize)).(*ebiten.Image), &options)
+
+		width := biasBarWidth * float32(t.pieceCounts[style]) / float32(maxCount)
+		vector.DrawFilledRect(screen, float32(x+biasBarHeight), float32(y+4), width, float32(biasBarHeight-8), barColor, false)
+
+		y += biasBarHeight
+	}
+}
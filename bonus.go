@@ -0,0 +1,147 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/loig/ebitenginegamejam2024/assets"
+)
+
+// Some of the maluses offered between levels come with a bonus, the
+// bonus is taken along with the malus when the offer is chosen.
+
+const (
+	bonusNone    int = iota
+	bonusMoney       // more coins at the end of the run
+	bonusHeart       // one more heart during the next level
+	bonusImprove     // a free improvement level
+	bonusPreview     // less preview corruption during the next level
+	numBonuses
+)
+
+// row of assets.ImageTextMalus explaining each bonus
+var gBonusDescriptions = [numBonuses]int{
+	bonusMoney:   15,
+	bonusHeart:   16,
+	bonusImprove: 17,
+	bonusPreview: 18,
+}
+
+// frames for showing the description of a malus or of its bonus
+const bonusDescriptionFrames int = 120
+
+// size in pixels of a bonus drawn on a malus icon
+const bonusSize float64 = 90
+
+type bonus struct {
+	kind        int
+	improvement int // improvement given by bonusImprove
+}
+
+// draw the maluses offered after the current level,
+// and pair some of them with a bonus
+func (g *game) offerMaluses() {
+	g.balance.getChoice(g.level, g.improv.levels[improveCleanse] > 0)
+	for i, choice := range g.balance.choices[:g.balance.numChoices] {
+		if choice >= 0 && gRandom.Intn(100) < gRules.BonusChance {
+			g.balance.bonuses[i] = g.pickBonus()
+		}
+	}
+}
+
+// choose a bonus among the ones that would have an effect
+func (g game) pickBonus() bonus {
+
+	kinds := []int{bonusMoney, bonusHeart}
+
+	var improvements []int
+	for i := range g.improv.levels {
+		if g.improv.levels[i] < len(g.improv.prices[i]) {
+			improvements = append(improvements, i)
+		}
+	}
+	if len(improvements) > 0 {
+		kinds = append(kinds, bonusImprove)
+	}
+
+	if preview, _ := findMalus("preview"); g.balance.levels[preview] > 0 {
+		kinds = append(kinds, bonusPreview)
+	}
+
+	b := bonus{kind: kinds[gRandom.Intn(len(kinds))]}
+	if b.kind == bonusImprove {
+		b.improvement = improvements[gRandom.Intn(len(improvements))]
+	}
+
+	return b
+}
+
+// set the effects of a bonus on the run
+func (g *game) takeBonus(b bonus) {
+	switch b.kind {
+	case bonusMoney:
+		g.money.bonus += gRules.BonusMoney
+	case bonusHeart:
+		g.bonusHearts++
+	case bonusImprove:
+		g.improv.levels[b.improvement]++
+	case bonusPreview:
+		g.bonusPreview = true
+	}
+}
+
+// forget the bonuses that only last one level
+func (g *game) endLevelBonuses() {
+	g.bonusHearts = 0
+	g.bonusPreview = false
+}
+
+// draw a bonus centered on x, y
+func (b bonus) draw(screen *ebiten.Image, x, y float64, gray uint8) {
+
+	var icon *ebiten.Image
+	switch b.kind {
+	case bonusMoney:
+		icon = assets.ImageCoin
+	case bonusHeart:
+		icon = assets.ImageFullHeart
+	case bonusImprove:
+		icon = improvementIcon(b.improvement)
+	case bonusPreview:
+		icon = assets.ImageBonusPreview
+	default:
+		return
+	}
+
+	drawBadge(screen, icon, x, y, gray)
+}
+
+// draw an icon with the size of a bonus, centered on x, y
+func drawBadge(screen, icon *ebiten.Image, x, y float64, gray uint8) {
+
+	scaling := bonusSize / float64(icon.Bounds().Dx())
+
+	options := ebiten.DrawImageOptions{}
+	options.ColorScale.ScaleWithColor(color.Gray{gray})
+	options.GeoM.Scale(scaling, scaling)
+	options.GeoM.Translate(x-bonusSize/2, y-bonusSize/2)
+	screen.DrawImage(icon, &options)
+}
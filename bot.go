@@ -0,0 +1,195 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+// Simple bot playing the game for the simulator. For each new piece
+// it looks at all the rotations and columns where the piece can be
+// dropped, keeps the one giving the best looking grid, and then
+// produces the key presses for getting there, as a player would.
+
+// weights for evaluating a grid
+const (
+	botHeightWeight    float64 = -0.51
+	botLinesWeight     float64 = 0.76
+	botHolesWeight     float64 = -0.36
+	botBumpinessWeight float64 = -0.18
+	botDangerWeight    float64 = -10
+)
+
+type bot struct {
+	piece   int // piece for which the target was computed
+	targetX int
+	targetR int
+	frame   int
+}
+
+// get the inputs for the next frame of a game
+func (b *bot) play(g game) (input inputState) {
+
+	b.frame++
+
+	switch g.state {
+	case stateBalance:
+		// take the choice put first by the game
+		input.justPressed[inputEnter] = !g.balance.inTransition && b.frame%2 == 0
+		return
+	case statePlay:
+	default:
+		return
+	}
+
+	t := g.currentPlay
+	if t.inAnimation || t.dead {
+		return
+	}
+
+	if b.piece != t.numPieces {
+		b.piece = t.numPieces
+		b.targetR, b.targetX = botTarget(t)
+	}
+
+	// keys are pressed every other frame, so that each press is a new
+	// one (rotations and moves are triggered when pressing a key)
+	if b.frame%2 == 0 {
+		return
+	}
+
+	block := t.currentBlock
+	switch {
+	case block.r != b.targetR:
+		input.justPressed[inputSpace] = true
+	case block.x > b.targetX:
+		input.pressed[inputLeft] = true
+	case block.x < b.targetX:
+		input.pressed[inputRight] = true
+	default:
+		input.pressed[inputDown] = true
+	}
+
+	// the bot is not fooled by mirrored controls
+	if t.mirrored {
+		input = input.mirrored()
+	}
+
+	return
+}
+
+// find the best rotation and column for the current block
+func botTarget(t tetris) (bestR, bestX int) {
+
+	bestR, bestX = t.currentBlock.r, t.currentBlock.x
+	bestScore := 0.0
+	found := false
+
+	for r := 0; r < t.rotationStates(); r++ {
+		rotated := t.currentBlock
+		if !botCanRotate(&rotated, r, t.rotationStates(), t.area) {
+			continue
+		}
+		for x := -3; x < gPlayAreaWidthInBlocks; x++ {
+			moved := rotated
+			if !botCanMove(&moved, x, t.area) {
+				continue
+			}
+			grid := t.area
+			moved.landingPosition(t.area).writeInGrid(&grid)
+			score := botEvaluate(grid, t.deathLines)
+			if !found || score > bestScore {
+				bestR, bestX, bestScore, found = r, x, score, true
+			}
+		}
+	}
+
+	return
+}
+
+// rotate a block right up to rotation r, as the bot would do
+func botCanRotate(block *tetrisBlock, r, states int, grid tetrisGrid) bool {
+	for block.r != r {
+		if !block.rotateRight(grid, states) {
+			return false
+		}
+	}
+	return true
+}
+
+// move a block up to column x, as the bot would do
+func botCanMove(block *tetrisBlock, x int, grid tetrisGrid) bool {
+	for block.x > x {
+		if !block.moveLeft(grid) {
+			return false
+		}
+	}
+	for block.x < x {
+		if !block.moveRight(grid) {
+			return false
+		}
+	}
+	return true
+}
+
+// evaluate a grid after a piece was added to it
+func botEvaluate(grid tetrisGrid, deathLines int) (score float64) {
+
+	var heights [gPlayAreaWidthInBlocks]int
+	holes := 0
+	lines := 0
+	danger := 0
+
+	for y, line := range grid {
+		if isLineComplete(line) {
+			lines++
+			continue
+		}
+		for x, style := range line {
+			if style == wallStyle {
+				continue
+			}
+			if style != noStyle {
+				if heights[x] == 0 {
+					heights[x] = len(grid) - y
+				}
+				if y < gInvisibleLines+deathLines {
+					danger++
+				}
+			} else if heights[x] > 0 {
+				holes++
+			}
+		}
+	}
+
+	height, bumpiness := 0, 0
+	for x, h := range heights {
+		height += h
+		if x > 0 {
+			bumpiness += abs(h - heights[x-1])
+		}
+	}
+
+	return botHeightWeight*float64(height) + botLinesWeight*float64(lines) +
+		botHolesWeight*float64(holes) + botBumpinessWeight*float64(bumpiness) +
+		botDangerWeight*float64(danger)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,70 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/loig/ebitenginegamejam2024/assets"
+)
+
+// The level of a malus goes down when it is cleansed. At each level
+// one of the maluses is the target of a cleansing, it is lowered if
+// a tetris is done during the level. A cleansing can also be offered
+// between levels, rarely or every time when cleanse charges were
+// bought in the shop.
+
+const cleanseTargetSize float64 = 64 // size of the target icon in pixels
+
+// lower the target malus if a tetris was done in the level
+func (g *game) cleanseByTetris() {
+	if g.cleanseTarget >= 0 && g.currentPlay.tetrises > 0 && g.balance.levels[g.cleanseTarget] > 0 {
+		g.balance.cleanse(g.cleanseTarget)
+	}
+	g.cleanseTarget = -1
+}
+
+// show the malus that a tetris cleanses, on the left of the play area
+func (g game) drawCleanseTarget(screen *ebiten.Image, gray uint8) {
+
+	if g.cleanseTarget < 0 {
+		return
+	}
+
+	x := (float64(gPlayAreaSide) - cleanseTargetSize) / 2
+	y := float64(gPlayAreaSide) / 2
+
+	options := ebiten.DrawImageOptions{}
+	options.ColorScale.ScaleWithColor(color.Gray{gray})
+	scaling := cleanseTargetSize / float64(gChoiceSize)
+	options.GeoM.Scale(scaling, scaling)
+	options.GeoM.Translate(x, y)
+	screen.DrawImage(gMaluses[g.cleanseTarget].Icon(), &options)
+
+	if g.currentPlay.tetrises > 0 {
+		// done, the cleansing is shown in the corner of the icon
+		options := ebiten.DrawImageOptions{}
+		options.ColorScale.ScaleWithColor(color.Gray{gray})
+		scaling := cleanseTargetSize / 2 / float64(assets.ImageCleanse.Bounds().Dx())
+		options.GeoM.Scale(scaling, scaling)
+		options.GeoM.Translate(x+cleanseTargetSize/2, y)
+		screen.DrawImage(assets.ImageCleanse, &options)
+	}
+}
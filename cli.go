@@ -0,0 +1,206 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// game modes
+const (
+	modeStandard string = "standard" // reach the goal level, choosing maluses
+	modeEndless  string = "endless"  // no goal level
+	modePractice string = "practice" // no maluses are offered between levels
+)
+
+// options given on the command line, or in a config file
+type options struct {
+	Seed       int64   `json:"seed"`
+	Mode       string  `json:"mode"`
+	Level      int     `json:"level"`
+	GoalLevel  int     `json:"goal"`
+	NumChoices int     `json:"choices"`
+	Scale      float64 `json:"scale"`
+	Fullscreen bool    `json:"fullscreen"`
+	Mute       bool    `json:"mute"`
+	Replay     string  `json:"replay"`
+	Record     string  `json:"record"`
+	SkipIntro  bool    `json:"skipIntro"`
+	Dev        bool    `json:"dev"`
+	Rules      string  `json:"rules"`
+	Progress   string  `json:"progress"`
+}
+
+func defaultOptions() options {
+	return options{
+		Mode:  modeStandard,
+		Level: 1,
+		Dev:   gDebug,
+	}
+}
+
+// read the options from the command line arguments (without the
+// program name), usage is written to output when they are not valid
+func parseOptions(args []string, output io.Writer) (opts options, err error) {
+
+	opts = defaultOptions()
+	flagOpts := opts
+	var configPath string
+
+	fs := flag.NewFlagSet("yatc", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.Usage = func() {
+		fmt.Fprintln(output, "Usage: yatc [options]")
+		fs.PrintDefaults()
+	}
+
+	fs.Int64Var(&flagOpts.Seed, "seed", opts.Seed, "seed of the random generator (0 for a random seed)")
+	fs.StringVar(&flagOpts.Mode, "mode", opts.Mode, "game mode: "+modeStandard+", "+modeEndless+" or "+modePractice)
+	fs.IntVar(&flagOpts.Level, "level", opts.Level, "starting level")
+	fs.IntVar(&flagOpts.GoalLevel, "goal", opts.GoalLevel, "level to reach for winning, ignored in "+modeEndless+" mode (0 for the value of the rules)")
+	fs.IntVar(&flagOpts.NumChoices, "choices", opts.NumChoices, fmt.Sprintf("number of maluses to choose from between levels, 1 to %d (0 for the value of the rules)", len(gMaluses)))
+	fs.Float64Var(&flagOpts.Scale, "scale", opts.Scale, "window scale, relative to 1280x1152 (0 to keep the default size)")
+	fs.BoolVar(&flagOpts.Fullscreen, "fullscreen", opts.Fullscreen, "start in fullscreen")
+	fs.BoolVar(&flagOpts.Mute, "mute", opts.Mute, "disable music and sounds")
+	fs.StringVar(&configPath, "config", "", "JSON file giving default values for these options")
+	fs.StringVar(&flagOpts.Rules, "rules", opts.Rules, "JSON file for tuning the rules of the game (see rules.go)")
+	fs.StringVar(&flagOpts.Replay, "replay", opts.Replay, "replay file to play")
+	fs.StringVar(&flagOpts.Record, "record", opts.Record, "file for recording the replay of the next run")
+	fs.StringVar(&flagOpts.Progress, "progress", opts.Progress, "file keeping the tiers unlocked and the best scores from one launch to the next")
+	fs.BoolVar(&flagOpts.SkipIntro, "skip-intro", opts.SkipIntro, "skip the controls screen")
+	fs.BoolVar(&flagOpts.Dev, "dev", opts.Dev, "enable the developer overlay (F3) and console (`)")
+
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	if fs.NArg() > 0 {
+		err = fmt.Errorf("unexpected argument %q", fs.Arg(0))
+		fmt.Fprintln(output, err)
+		fs.Usage()
+		return
+	}
+
+	if configPath != "" {
+		if err = opts.load(configPath); err != nil {
+			fmt.Fprintln(output, err)
+			return
+		}
+	}
+
+	// the command line has priority over the config file
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "seed":
+			opts.Seed = flagOpts.Seed
+		case "mode":
+			opts.Mode = flagOpts.Mode
+		case "level":
+			opts.Level = flagOpts.Level
+		case "goal":
+			opts.GoalLevel = flagOpts.GoalLevel
+		case "choices":
+			opts.NumChoices = flagOpts.NumChoices
+		case "scale":
+			opts.Scale = flagOpts.Scale
+		case "fullscreen":
+			opts.Fullscreen = flagOpts.Fullscreen
+		case "mute":
+			opts.Mute = flagOpts.Mute
+		case "replay":
+			opts.Replay = flagOpts.Replay
+		case "record":
+			opts.Record = flagOpts.Record
+		case "skip-intro":
+			opts.SkipIntro = flagOpts.SkipIntro
+		case "dev":
+			opts.Dev = flagOpts.Dev
+		case "rules":
+			opts.Rules = flagOpts.Rules
+		case "progress":
+			opts.Progress = flagOpts.Progress
+		}
+	})
+
+	if opts.Rules != "" {
+		if gRules, err = loadRules(opts.Rules); err != nil {
+			fmt.Fprintln(output, err)
+			return
+		}
+	}
+	if opts.GoalLevel == 0 {
+		opts.GoalLevel = gRules.GoalLevel
+	}
+	if opts.NumChoices == 0 {
+		opts.NumChoices = gRules.NumChoices
+	}
+
+	if err = opts.validate(); err != nil {
+		fmt.Fprintln(output, err)
+		fs.Usage()
+	}
+
+	return
+}
+
+// read options from a JSON file, fields that are not in
+// the file keep their current values
+func (o *options) load(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(o); err != nil {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	return nil
+}
+
+func (o options) validate() error {
+	var errs []error
+
+	if o.Mode != modeStandard && o.Mode != modeEndless && o.Mode != modePractice {
+		errs = append(errs, fmt.Errorf("mode: %q is not one of %s, %s, %s", o.Mode, modeStandard, modeEndless, modePractice))
+	}
+	if o.GoalLevel < 1 {
+		errs = append(errs, fmt.Errorf("goal: must be at least 1, got %d", o.GoalLevel))
+	}
+	if o.Level < 1 || (o.Mode != modeEndless && o.Level > o.GoalLevel) {
+		errs = append(errs, fmt.Errorf("level: must be between 1 and the goal level (%d), got %d", o.GoalLevel, o.Level))
+	}
+	if o.NumChoices < 1 || o.NumChoices > len(gMaluses) {
+		errs = append(errs, fmt.Errorf("choices: must be between 1 and %d, got %d", len(gMaluses), o.NumChoices))
+	}
+	if o.Scale < 0 {
+		errs = append(errs, fmt.Errorf("scale: must be positive, got %g", o.Scale))
+	}
+	if o.Replay != "" && o.Replay == o.Record {
+		errs = append(errs, errors.New("record: cannot record to the replay being played"))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,115 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	darknessAlpha      float32 = 0.95 // opacity of the darkness
+	darknessLightSize  int     = 256  // radius of the light image in pixels
+	darknessLightInner float64 = 0.6  // part of the light radius that is fully lit
+)
+
+var darknessColor = color.RGBA{8, 24, 32, 255}
+
+// darkness over the play area, and the light that is cut out from it
+var (
+	gDarknessImage *ebiten.Image
+	gLightImage    *ebiten.Image
+)
+
+// light with a soft border, as a mask
+func newLightImage() *ebiten.Image {
+	size := 2 * darknessLightSize
+	light := image.NewAlpha(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dx := float64(x-darknessLightSize) + 0.5
+			dy := float64(y-darknessLightSize) + 0.5
+			d := (dx*dx + dy*dy) / float64(darknessLightSize*darknessLightSize)
+			inner := darknessLightInner * darknessLightInner
+			switch {
+			case d <= inner:
+				light.SetAlpha(x, y, color.Alpha{255})
+			case d < 1:
+				light.SetAlpha(x, y, color.Alpha{uint8(255 * (1 - d) / (1 - inner))})
+			}
+		}
+	}
+	return ebiten.NewImageFromImage(light)
+}
+
+// center of the active piece in pixels, relative to the play area
+func (t tetris) lightCenter() (x, y float64) {
+	squares := blockSquares(t.currentBlock)
+	if len(squares) == 0 {
+		return float64(gPlayAreaWidth) / 2, float64(gPlayAreaHeight) / 2
+	}
+	for _, pos := range squares {
+		x += float64(pos[0]) + 0.5
+		y += float64(pos[1]-gInvisibleLines) + 0.5
+	}
+	x *= float64(gSquareSideSize) / float64(len(squares))
+	y *= float64(gSquareSideSize) / float64(len(squares))
+	return
+}
+
+// darkness over the play area, except around the active piece, it is
+// lifted for a moment when lines are removed
+func (t tetris) drawDarkness(screen *ebiten.Image, gray uint8) {
+	if t.darknessLevel <= 0 {
+		return
+	}
+
+	if gDarknessImage == nil {
+		gDarknessImage = ebiten.NewImage(gPlayAreaWidth, gPlayAreaHeight)
+		gLightImage = newLightImage()
+	}
+
+	alpha := darknessAlpha
+	if t.removeLineAnimationStep > 0 {
+		frames := 7 * t.removeLineAnimationStepNumFrames
+		elapsed := (t.removeLineAnimationStep-1)*t.removeLineAnimationStepNumFrames + t.removeLineAnimationFrame
+		alpha *= float32(elapsed) / float32(frames)
+	}
+
+	gDarknessImage.Fill(grayColor(darknessColor, gray))
+
+	if t.removeLineAnimationStep == 0 && !t.dead {
+		x, y := t.lightCenter()
+		radius := float64(ruleAt(gRules.DarknessRadius, t.darknessLevel-1)) / 10
+		scaling := radius * float64(gSquareSideSize) / float64(darknessLightSize)
+		options := ebiten.DrawImageOptions{}
+		options.Blend = ebiten.BlendDestinationOut
+		options.GeoM.Translate(-float64(darknessLightSize), -float64(darknessLightSize))
+		options.GeoM.Scale(scaling, scaling)
+		options.GeoM.Translate(x, y)
+		gDarknessImage.DrawImage(gLightImage, &options)
+	}
+
+	options := ebiten.DrawImageOptions{}
+	options.ColorScale.ScaleAlpha(alpha)
+	options.GeoM.Translate(float64(gPlayAreaSide), 0)
+	screen.DrawImage(gDarknessImage, &options)
+}
@@ -0,0 +1,350 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	devOverlayKey     = ebiten.KeyF3
+	devConsoleKey     = ebiten.KeyBackquote
+	devTextScale      = 2  // scaling of the debug font
+	devLineHeight     = 16 // height of a line of the debug font in pixels
+	devMaxOutputLines = 8
+)
+
+// names of the states in the overlay
+var gDevStateNames = []string{
+	stateTitle:    "title",
+	statePlay:     "play",
+	stateBalance:  "balance",
+	stateLost:     "lost",
+	stateImprove:  "improve",
+	stateWon:      "won",
+	stateControls: "controls",
+	stateCredits:  "credits",
+}
+
+// developer overlay and console, only available in debug builds
+type devConsole struct {
+	overlay    bool
+	open       bool
+	input      []rune
+	output     []string
+	lastUpdate time.Time
+	frameTime  time.Duration
+	textImage  *ebiten.Image
+}
+
+// handle the console, the game should not be updated when
+// paused is true, so that the console can be used freely
+func (g *game) updateDevConsole() (paused bool) {
+
+	c := &g.console
+
+	now := time.Now()
+	if !c.lastUpdate.IsZero() {
+		c.frameTime = now.Sub(c.lastUpdate)
+	}
+	c.lastUpdate = now
+
+	if inpututil.IsKeyJustPressed(devOverlayKey) {
+		c.overlay = !c.overlay
+	}
+
+	if inpututil.IsKeyJustPressed(devConsoleKey) {
+		c.open = !c.open
+		c.input = c.input[:0]
+		return true
+	}
+
+	if !c.open {
+		return false
+	}
+
+	for _, r := range ebiten.AppendInputChars(nil) {
+		if r != '`' && r != '~' {
+			c.input = append(c.input, r)
+		}
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyBackspace) && len(c.input) > 0 {
+		c.input = c.input[:len(c.input)-1]
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		c.open = false
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+		command := string(c.input)
+		c.input = c.input[:0]
+		c.print("> " + command)
+		if err := g.runDevCommand(command); err != nil {
+			c.print(err.Error())
+		}
+	}
+
+	return true
+}
+
+func (c *devConsole) print(text string) {
+	c.output = append(c.output, strings.Split(text, "\n")...)
+	if len(c.output) > devMaxOutputLines {
+		c.output = c.output[len(c.output)-devMaxOutputLines:]
+	}
+}
+
+// execute one console command
+func (g *game) runDevCommand(command string) error {
+
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil
+	}
+
+	inRun := g.state == statePlay || g.state == stateBalance
+
+	if args[0] != "help" && args[0] != "dump" {
+		g.cheated = true
+	}
+
+	intArg := func(pos int) (int, error) {
+		if len(args) <= pos {
+			return 0, fmt.Errorf("%s: missing argument", args[0])
+		}
+		v, err := strconv.Atoi(args[pos])
+		if err != nil {
+			return 0, fmt.Errorf("%s: %q is not a number", args[0], args[pos])
+		}
+		return v, nil
+	}
+
+	switch args[0] {
+	case "help":
+		g.console.print("money N, malus NAME N, level N, spawn I|O|J|L|S|T|Z,")
+		g.console.print("fill N, seed N, tier N, dump, win, lose")
+		g.console.print("maluses: " + strings.Join(malusIDs(), ", "))
+
+	case "money":
+		money, err := intArg(1)
+		if err != nil {
+			return err
+		}
+		g.money.money = money
+		g.money.displayMoney = money
+
+	case "seed":
+		seed, err := intArg(1)
+		if err != nil {
+			return err
+		}
+		seedRandom(int64(seed))
+
+	case "malus":
+		if !inRun {
+			return fmt.Errorf("malus: no run in progress")
+		}
+		if len(args) < 2 {
+			return fmt.Errorf("malus: missing name")
+		}
+		id, found := findMalus(args[1])
+		if !found {
+			return fmt.Errorf("malus: unknown malus %q", args[1])
+		}
+		level, err := intArg(2)
+		if err != nil {
+			return err
+		}
+		if level < 0 || level > gMaluses[id].MaxLevel() {
+			return fmt.Errorf("malus: level of %s must be between 0 and %d", args[1], gMaluses[id].MaxLevel())
+		}
+		g.balance.levels[id] = level
+		g.state = statePlay
+		g.startLevel(g.currentPlay.score, g.currentPlay.currentLife)
+
+	case "level":
+		if !inRun {
+			return fmt.Errorf("level: no run in progress")
+		}
+		level, err := intArg(1)
+		if err != nil {
+			return err
+		}
+		if goal := g.runGoalLevel(); level < 1 || (goal > 0 && level > goal) {
+			return fmt.Errorf("level: must be between 1 and %d", goal)
+		}
+		g.level = level - 1
+		g.state = statePlay
+		g.startLevel(g.currentPlay.score, g.currentPlay.currentLife)
+
+	case "tier":
+		if inRun {
+			return fmt.Errorf("tier: a run is in progress")
+		}
+		tier, err := intArg(1)
+		if err != nil {
+			return err
+		}
+		if tier < 0 || tier > len(gRules.Tiers) {
+			return fmt.Errorf("tier: must be between 0 and %d", len(gRules.Tiers))
+		}
+		g.progress.MaxTier = max(g.progress.MaxTier, tier)
+		g.tier = tier
+
+	case "spawn":
+		if g.state != statePlay {
+			return fmt.Errorf("spawn: not playing")
+		}
+		if len(args) < 2 {
+			return fmt.Errorf("spawn: missing piece")
+		}
+		style, ok := runeToStyle([]rune(strings.ToUpper(args[1]))[0])
+		block, isBlock := getBlockOfStyle(style)
+		if !ok || !isBlock {
+			return fmt.Errorf("spawn: unknown piece %q", args[1])
+		}
+		block.setInitialPosition()
+		g.currentPlay.currentBlock = block
+
+	case "fill":
+		if g.state != statePlay {
+			return fmt.Errorf("fill: not playing")
+		}
+		lines, err := intArg(1)
+		if err != nil {
+			return err
+		}
+		if lines < 0 || lines > gPlayAreaHeightInBlocks {
+			return fmt.Errorf("fill: must be between 0 and %d", gPlayAreaHeightInBlocks)
+		}
+		g.currentPlay.pushGarbage(lines)
+
+	case "dump":
+		log.Print("\n" + g.currentPlay.toText(g.fog))
+		g.console.print("board written to the log")
+
+	case "win":
+		g.state = stateWon
+		g.winFrame = 0
+		g.audio.StopMusic()
+		g.stopRecording()
+		g.endRun(true)
+
+	case "lose":
+		if !inRun {
+			return fmt.Errorf("lose: no run in progress")
+		}
+		g.state = stateLost
+		g.money.addScore(g.currentPlay.score)
+		g.stopRecording()
+		g.endRun(false)
+
+	default:
+		return fmt.Errorf("unknown command %q, try help", args[0])
+	}
+
+	return nil
+}
+
+// text of the overlay giving the internals of the game
+func (g game) devOverlayText() string {
+
+	var sb strings.Builder
+	t := g.currentPlay
+
+	fmt.Fprintf(&sb, "frame %.1fms (%.1f tps, %.1f fps)\n", float64(g.console.frameTime.Microseconds())/1000, ebiten.ActualTPS(), ebiten.ActualFPS())
+	fmt.Fprintf(&sb, "state %s level %d/%d tier %d/%d seed %d\n", gDevStateNames[g.state], g.level+1, g.runGoalLevel(), g.tier, g.progress.MaxTier, gSeed)
+
+	sb.WriteString("malus")
+	for id, m := range gMaluses {
+		fmt.Fprintf(&sb, " %s %d/%d", m.ID(), g.balance.levels[id], m.MaxLevel())
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("improve")
+	for id, imp := range gImprovements {
+		fmt.Fprintf(&sb, " %s %d", imp.id, g.improv.levels[id])
+	}
+	sb.WriteString("\n")
+	fmt.Fprintf(&sb, "reroll cost %d skip chance %d%% cost %d\n", g.rerollCost(), g.balance.skipChance, gRules.SkipCost)
+	fmt.Fprintf(&sb, "bonus money %d%% hearts %d preview %t\n", g.money.bonus, g.bonusHearts, g.bonusPreview)
+	fmt.Fprintf(&sb, "cleanse target %d tetrises %d charges %d\n", g.cleanseTarget, t.tetrises, g.improv.levels[improveCleanse])
+	fmt.Fprintf(&sb, "slow charges %d frames %d\n", g.slowCharges, t.slowFrames)
+	fmt.Fprintf(&sb, "money %d score %d lines %d/%d\n", g.money.money, t.score, t.numLines, t.goalLines)
+	fmt.Fprintf(&sb, "fog %d/%d frame %d decreasing %t protection %d\n", g.fog.currentHiddenLines, g.fog.hiddenLines, g.fog.frame, g.fog.decreasing, g.fog.protectionLevel)
+	fmt.Fprintf(&sb, "gravity %d/%d down %d/%d lr %d/%d first %d/%d moves %t\n",
+		t.autoDownFrame, t.autoDownFrameLimit, t.manualDownFrame, t.manualDownFrameLimit,
+		t.lrMoveFrame, t.lrMoveFrameLimit, t.lrFirstMoveFrame, t.lrFirstMoveFrameLimit, t.manualMoveAllowed)
+	fmt.Fprintf(&sb, "invisible %d/%d frame %d animation %d life %d/%d\n", t.invisibleStep, t.invisibleLevel, t.invisibleFrame, t.removeLineAnimationStep, t.currentLife, t.life)
+	fmt.Fprintf(&sb, "mirror %d frame %d left %d mirrored %t\n", t.mirrorLevel, t.mirrorFrame, t.mirrorFramesLeft(), t.mirrored)
+	fmt.Fprintf(&sb, "rise %d/%d\n", t.riseCount, t.risePieces)
+	fmt.Fprintf(&sb, "preview %d off %t frame %d shown %s\n", t.previewLevel, t.previewOff, t.previewFrame, blockToText(t.previewBlock))
+	fmt.Fprintf(&sb, "bias %d since I %d\n", t.biasLevel, t.piecesSinceI)
+	fmt.Fprintf(&sb, "wind %d frame %d direction %d walls %d\n", t.windLevel, t.windFrame, t.windDirection, t.wallLevel)
+	fmt.Fprintf(&sb, "rotation %d cooldown %d states %d\n", t.rotationLevel, t.rotationCooldown, t.rotationStates())
+	fmt.Fprintf(&sb, "time %d/%d lost lives %d\n", t.timeLeft, t.timeLimit, t.lostLives)
+
+	return sb.String()
+}
+
+func (g *game) drawDevConsole(screen *ebiten.Image) {
+
+	c := &g.console
+
+	if !c.overlay && !c.open {
+		return
+	}
+
+	if c.textImage == nil {
+		c.textImage = ebiten.NewImage(gWidth/devTextScale, gHeight/devTextScale)
+	}
+	c.textImage.Clear()
+
+	y := 0
+	if c.overlay {
+		text := g.devOverlayText()
+		height := (strings.Count(text, "\n") + 1) * devLineHeight
+		vector.DrawFilledRect(c.textImage, 0, 0, float32(gWidth/devTextScale), float32(height), color.RGBA{0, 0, 0, 180}, false)
+		ebitenutil.DebugPrintAt(c.textImage, text, 2, y)
+		y += height
+	}
+
+	if c.open {
+		text := strings.Join(append(c.output, "> "+string(c.input)+"_"), "\n")
+		height := (len(c.output) + 1) * devLineHeight
+		vector.DrawFilledRect(c.textImage, 0, float32(y), float32(gWidth/devTextScale), float32(height), color.RGBA{40, 0, 40, 200}, false)
+		ebitenutil.DebugPrintAt(c.textImage, text, 2, y)
+	}
+
+	options := ebiten.DrawImageOptions{}
+	options.GeoM.Scale(devTextScale, devTextScale)
+	screen.DrawImage(c.textImage, &options)
+}
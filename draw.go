@@ -44,11 +44,13 @@ func (g *game) Draw(screen *ebiten.Image) {
 		} else {
 			drawArrow(screen, gWidth/2-250, 3*gHeight/4+128, math.Pi/2, g.titleFrame)
 		}
+		g.drawTier(screen)
 	case statePlay:
 		g.drawPlay(screen, 255)
 	case stateBalance:
 		g.drawPlay(screen, 100)
-		g.balance.draw(screen)
+		g.balance.draw(screen, g.level+1)
+		g.drawReroll(screen)
 	case stateLost:
 		g.drawPlay(screen, 100)
 		g.money.draw(screen)
@@ -63,6 +65,10 @@ func (g *game) Draw(screen *ebiten.Image) {
 		screen.DrawImage(assets.ImageRocket, &options)
 	}
 
+	if g.devMode {
+		g.drawDevConsole(screen)
+	}
+
 }
 
 func (g game) drawShop(screen *ebiten.Image) {
@@ -86,13 +92,23 @@ func (g game) drawPlay(screen *ebiten.Image, gray uint8) {
 	// draw current play
 	g.currentPlay.draw(screen, gray)
 	// draw number of lines destroyed
-	drawNumberAt(screen, gray, gWidth-gXLinesFromRightSide+gMultFactor, gYLinesFromTop, g.currentPlay.numLines, g.balance.getGoalLines())
+	drawNumberAt(screen, gray, gWidth-gXLinesFromRightSide+gMultFactor, gYLinesFromTop, g.currentPlay.numLines, g.currentPlay.goalLines)
 	// draw score
 	drawNumberAt(screen, gray, gWidth-gXScoreFromRightSide+gMultFactor, gYScoreFromTop, g.currentPlay.score, -1)
 	// draw level
-	drawNumberAt(screen, gray, gWidth-gXLevelFromRightSide+gMultFactor, gYLevelFromTop, g.level+1, g.goalLevel)
+	drawNumberAt(screen, gray, gWidth-gXLevelFromRightSide+gMultFactor, gYLevelFromTop, g.level+1, g.runGoalLevel())
 	// hide lines
 	g.fog.draw(screen, gray)
+	// darkness
+	g.currentPlay.drawDarkness(screen, gray)
+	// mirrored controls warning
+	g.currentPlay.drawMirror(screen, gray)
+	// announcement of gusts of wind
+	g.currentPlay.drawWind(screen, gray)
+	// malus cleansed by a tetris
+	g.drawCleanseTarget(screen, gray)
+	// charges of slow time
+	g.drawSlow(screen, gray)
 }
 
 func (g game) drawDeathLines(screen *ebiten.Image, gray uint8) {
@@ -0,0 +1,127 @@
+//go:build debug
+
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Entry points for fuzzing the board engine. They take raw bytes,
+// so they can be used as the body of a Go fuzz target or fed with
+// any byte source, and they report both invariant violations and
+// runtime panics (such as out of range accesses) as errors.
+// The fuzz targets are in fuzz_test.go, for example
+//
+//	go test -tags debug -fuzz FuzzTetrisUpdate
+
+// bits of a fuzzing input byte
+const (
+	fuzzDown byte = 1 << iota
+	fuzzLeft
+	fuzzRight
+	fuzzHold
+	fuzzRotateLeft
+	fuzzRotateRight
+)
+
+// run a tetris game where the first bytes of data give the seed and
+// the setup, and each following byte gives the inputs for one frame
+func fuzzTetrisUpdate(data []byte) (err error) {
+
+	if len(data) < 10 {
+		return nil
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	seedRandom(int64(binary.LittleEndian.Uint64(data)))
+
+	balance := newBalance(3, 0)
+	setup := data[8]
+	death, _ := findMalus("death")
+	speed, _ := findMalus("speed")
+	balance.levels[death] = int(setup) % (maxLevelDeathLines + 1)
+	balance.levels[speed] = int(setup>>3) % (maxLevelSpeed + 1)
+	level := int(data[9]) % len(gRules.Speeds)
+	life := int(setup>>6) % 3
+
+	var t tetris
+	t.newRun()
+	t.init(balance, level, 0, setup&0x4 != 0, setup&0x20 != 0, life, life)
+
+	for _, input := range data[10:] {
+		t.update(
+			input&fuzzDown != 0, input&fuzzLeft != 0, input&fuzzRight != 0,
+			input&fuzzHold != 0, input&fuzzRotateLeft != 0, input&fuzzRotateRight != 0,
+			level,
+		)
+		if err := t.checkInvariants(); err != nil {
+			return fmt.Errorf("%v\n%s", err, t.toText(fog{}))
+		}
+		if t.dead && !t.inAnimation {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// check lines removal on a grid given as text (see textToGrid), the
+// lines touched by the last locked piece are given by the two bytes
+// of check, as they would have been by writeInGrid
+func fuzzRemoveLines(text string, check [2]byte) (err error) {
+
+	grid, err := textToGrid(text)
+	if err != nil || checkGrid(grid) != nil {
+		return nil
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	var t tetris
+	t.area = grid
+	t.toCheck[0] = int(check[0]) % len(grid)
+	t.toCheck[1] = t.toCheck[0] + int(check[1])%4
+	if t.toCheck[1] >= len(grid) {
+		t.toCheck[1] = len(grid) - 1
+	}
+
+	// only lines touched by the piece can be complete
+	for y, line := range grid {
+		if isLineComplete(line) && (y < t.toCheck[0] || y > t.toCheck[1]) {
+			return nil
+		}
+	}
+
+	t.toRemoveNum, t.firstAvailable, t.toRemove = t.checkLines()
+	t.removeLines()
+
+	return checkRemoval(grid, t.area, t.toRemoveNum)
+}
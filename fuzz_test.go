@@ -0,0 +1,50 @@
+//go:build debug
+
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import "testing"
+
+func FuzzTetrisUpdate(f *testing.F) {
+	// seed, setup, speed level, then inputs for each frame
+	f.Add([]byte{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	f.Add([]byte{42, 0, 0, 0, 0, 0, 0, 0, 0x3d, 10,
+		fuzzDown, fuzzDown | fuzzLeft, fuzzRotateLeft, fuzzHold, fuzzRight, fuzzRotateRight, fuzzDown, fuzzDown})
+	f.Add([]byte{7, 1, 2, 3, 4, 5, 6, 7, 0xff, 20,
+		fuzzLeft, fuzzLeft, fuzzLeft, fuzzDown, fuzzDown, fuzzDown, fuzzHold, fuzzRight, fuzzRight, fuzzDown})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := fuzzTetrisUpdate(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzRemoveLines(f *testing.F) {
+	f.Add("|..........|\n|IIIITTTJOO|\n", byte(19), byte(1))
+	f.Add("|.....S....|\n|LLLLLSSIII|\n|L..ZZ.S...|\n|LLLLLZZIII|\n", byte(17), byte(3))
+	f.Add("|=IIIIOOJJ=|\n|XXXX.XXXXX|\n", byte(19), byte(0))
+
+	f.Fuzz(func(t *testing.T, text string, first, size byte) {
+		if err := fuzzRemoveLines(text, [2]byte{first, size}); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -46,13 +46,88 @@ type game struct {
 	titleSelect int
 	titleFrame  int
 	winFrame    int
+	devMode     bool
+	console     devConsole
+	mode        string
+	firstLevel  int
+	input       inputState
+	recording   *replay
+	recordPath  string
+	replaying   *replay
+	replayFrame int
+
+	// bonuses lasting for the current level
+	bonusHearts  int
+	bonusPreview bool
+
+	cleanseTarget int // malus lowered by a tetris during the current level, -1 for none
+
+	slowCharges int // charges of slow time left in the run
+
+	tier         int // tier of difficulty of the runs
+	progress     progress
+	progressPath string
+	cheated      bool // dev commands changed the game, no more runs are recorded in the progress
 }
 
-func (g *game) init() {
+func (g *game) init(opts options) error {
 	g.audio = assets.InitAudio()
+	g.audio.Muted = opts.Mute
 	g.state = stateControls
+	if opts.SkipIntro {
+		g.state = stateTitle
+	}
 	g.firstPlay = true
-	g.numChoices = 3
+	g.mode = opts.Mode
+	g.numChoices = opts.NumChoices
 	g.improv = setupImprovements()
-	g.goalLevel = 11
+	g.goalLevel = opts.GoalLevel
+	if g.mode == modeEndless {
+		g.goalLevel = 0
+	}
+	g.firstLevel = opts.Level - 1
+	g.level = g.firstLevel
+	g.devMode = opts.Dev
+	g.recordPath = opts.Record
+	g.progressPath = opts.Progress
+
+	if g.progressPath != "" {
+		p, err := loadProgress(g.progressPath)
+		if err != nil {
+			return err
+		}
+		g.progress = p
+		g.tier = p.MaxTier
+	}
+
+	if opts.Seed != 0 {
+		seedRandom(opts.Seed)
+	}
+
+	if opts.Replay != "" {
+		r, err := loadReplay(opts.Replay)
+		if err != nil {
+			return err
+		}
+		g.startReplay(r)
+	}
+
+	return nil
+}
+
+// start a new run from the title screen or a replay, the seed
+// is set at each run so that any run can be replayed
+func (g *game) startRun(seed int64) {
+	seedRandom(seed)
+	if g.recordPath != "" {
+		g.startRecording(seed)
+	}
+	g.state = statePlay
+	g.balance = newBalance(g.numChoices, g.improv.levels[improveSkip])
+	g.balance.setTier(g.tierRules(), g.improvedLife())
+	g.money.bonus = 0
+	g.slowCharges = 0
+	g.endLevelBonuses()
+	g.currentPlay.newRun()
+	g.startLevel(0, g.maxLife())
 }
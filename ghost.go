@@ -0,0 +1,135 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/loig/ebitenginegamejam2024/assets"
+)
+
+// The ghost improvement shows where the current piece would land:
+// its outline from level 1, its shape from level 2, and from level 3
+// the lines that the piece would complete are highlighted.
+const (
+	ghostLevelOutline int = 1
+	ghostLevelShape   int = 2
+	ghostLevelLines   int = 3
+)
+
+const (
+	ghostOutlineWidth float32 = 4
+	ghostShapeAlpha   float32 = 0.3
+)
+
+var (
+	ghostOutlineColor = color.RGBA{150, 130, 150, 255}
+	ghostLineColor    = color.RGBA{88, 85, 73, 90} // cream, transparent
+)
+
+// position where the current piece would land, if the ghost is shown
+func (t tetris) ghost() (block tetrisBlock, shown bool) {
+	if t.ghostLevel < ghostLevelOutline || t.currentBlock.id < 0 || t.removeLineAnimationStep != 0 || !t.currentBlockVisible() {
+		return block, false
+	}
+	return t.currentBlock.landingPosition(t.area), true
+}
+
+// draw the ghost of the current piece, xOrigin and yOrigin are
+// the position of the grid in pixels
+func (t tetris) drawGhost(screen *ebiten.Image, gray uint8, xOrigin, yOrigin int) {
+
+	block, shown := t.ghost()
+	if !shown || block.y == t.currentBlock.y {
+		return
+	}
+
+	squares := block.states[block.r]
+	filled := func(x, y int) bool {
+		return x >= 0 && x < len(squares[0]) && y >= 0 && y < len(squares) && squares[y][x]
+	}
+
+	c := grayColor(ghostOutlineColor, gray)
+	side := float32(gSquareSideSize)
+
+	for yRel, line := range squares {
+		for xRel, square := range line {
+			if !square {
+				continue
+			}
+			x := float32(xOrigin + (block.x+xRel)*gSquareSideSize)
+			y := float32(yOrigin + (block.y+yRel)*gSquareSideSize)
+
+			if t.ghostLevel >= ghostLevelShape {
+				options := ebiten.DrawImageOptions{}
+				options.ColorScale.ScaleWithColor(color.Gray{gray})
+				options.ColorScale.ScaleAlpha(ghostShapeAlpha)
+				options.GeoM.Translate(float64(x), float64(y))
+				screen.DrawImage(assets.ImageSquares.SubImage(image.Rect((block.style-1)*gSquareSideSize, 0, block.style*gSquareSideSize, gSquareSideSize)).(*ebiten.Image), &options)
+			}
+
+			// only the sides of the piece are outlined
+			half := ghostOutlineWidth / 2
+			if !filled(xRel, yRel-1) {
+				vector.StrokeLine(screen, x, y+half, x+side, y+half, ghostOutlineWidth, c, false)
+			}
+			if !filled(xRel, yRel+1) {
+				vector.StrokeLine(screen, x, y+side-half, x+side, y+side-half, ghostOutlineWidth, c, false)
+			}
+			if !filled(xRel-1, yRel) {
+				vector.StrokeLine(screen, x+half, y, x+half, y+side, ghostOutlineWidth, c, false)
+			}
+			if !filled(xRel+1, yRel) {
+				vector.StrokeLine(screen, x+side-half, y, x+side-half, y+side, ghostOutlineWidth, c, false)
+			}
+		}
+	}
+}
+
+// highlight the lines that the current piece would complete
+func (t tetris) drawGhostLines(screen *ebiten.Image, gray uint8, xOrigin, yOrigin int) {
+
+	if t.ghostLevel < ghostLevelLines {
+		return
+	}
+
+	block, shown := t.ghost()
+	if !shown {
+		return
+	}
+
+	grid := t.area
+	rows := block.writeInGrid(&grid)
+
+CheckLoop:
+	for y := rows[0]; y <= rows[1]; y++ {
+		for _, v := range grid[y] {
+			if v == noStyle {
+				continue CheckLoop
+			}
+		}
+		vector.DrawFilledRect(screen,
+			float32(xOrigin), float32(yOrigin+y*gSquareSideSize),
+			float32(gPlayAreaWidth), float32(gSquareSideSize),
+			grayColor(ghostLineColor, gray), false)
+	}
+}
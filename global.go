@@ -60,8 +60,6 @@ const (
 
 	gChoiceSelectionNumFrame int = 30 // number of frames for changing balancing choice
 
-	gSpeedLevels int = 21
-
 	gInvisibleNumFrames int = 60 // num frames for one step of invisibility
 
 	gCoinSideSize int = 128 // size of the side of the coin image in pixels
@@ -97,11 +95,6 @@ const (
 	gHeartWidth int = 70
 )
 
-var gSpeeds [gSpeedLevels]int = [gSpeedLevels]int{
-	53, 49, 45, 41, 37, 33, 28, 22, 17, 11, 10,
-	9, 8, 7, 6, 6, 5, 5, 4, 4, 3,
-}
-
 var gAnimRocket []int = []int{
 	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
 	1, 2, 3, 4, 5, 6, 7, 8, 9, 11, 13, 15, 17, 20, 23,
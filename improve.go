@@ -23,7 +23,6 @@ import (
 	"math"
 
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/loig/ebitenginegamejam2024/assets"
 )
 
@@ -31,46 +30,194 @@ const (
 	numArrowBlinkFrame int = 30
 )
 
+// improvements shown at once in the shop, the others are on the next pages
+const shopPageSize int = 4
+
 const (
 	improveLife int = iota
 	improveHold
 	improveResetAutoDown
 	improveHideMove
+	improveReroll
+	improveSkip
+	improveCleanse // charges, used when a cleansing is taken
+	improveGhost
+	improveSlow // stacks of charges, turned into charges of the run at the start of each level
 	numImprove
 )
 
+// An improvement is bought in the shop between runs, each purchase
+// raises its level, up to the number of prices given by the rules.
+type improvement struct {
+	id          string                    // name used on the command line and in the console
+	prices      func(p rulesPrices) []int // price of each level
+	icon        int                       // row of assets.ImageImprovements
+	description int                       // row of assets.ImageTextShop
+	apply       func(g *game, level int)  // effect set at the start of each level, nil for the improvements used otherwise
+}
+
+// all the improvements, in the order of the shop
+var gImprovements = [numImprove]improvement{
+	improveLife: {
+		id:     "life",
+		prices: func(p rulesPrices) []int { return p.Life },
+		// hearts are given by game.maxLife
+	},
+	improveHold: {
+		id:          "hold",
+		prices:      func(p rulesPrices) []int { return p.Hold },
+		icon:        1,
+		description: 1,
+		apply:       func(g *game, level int) { g.currentPlay.canHold = level > 0 },
+	},
+	improveResetAutoDown: {
+		id:          "rotation",
+		prices:      func(p rulesPrices) []int { return p.ResetAutoDown },
+		icon:        2,
+		description: 2,
+		apply:       func(g *game, level int) { g.currentPlay.betterRotation = level > 0 },
+	},
+	improveHideMove: {
+		id:          "fog",
+		prices:      func(p rulesPrices) []int { return p.HideMove },
+		icon:        3,
+		description: 3,
+		apply:       func(g *game, level int) { g.fog.reset(g.currentPlay.hiddenLines, level) },
+	},
+	improveReroll: {
+		id:          "reroll",
+		prices:      func(p rulesPrices) []int { return p.Reroll },
+		icon:        4,
+		description: 4,
+		// used between levels, see reroll.go
+	},
+	improveSkip: {
+		id:          "skip",
+		prices:      func(p rulesPrices) []int { return p.Skip },
+		icon:        5,
+		description: 5,
+		apply:       func(g *game, level int) { g.balance.setSkipLevel(level) },
+	},
+	improveCleanse: {
+		id:          "cleanse",
+		prices:      func(p rulesPrices) []int { return p.Cleanse },
+		icon:        6,
+		description: 6,
+		// charges used between levels, see cleanse.go
+	},
+	improveGhost: {
+		id:          "ghost",
+		prices:      func(p rulesPrices) []int { return p.Ghost },
+		icon:        7,
+		description: 7,
+		apply:       func(g *game, level int) { g.currentPlay.ghostLevel = level },
+	},
+	improveSlow: {
+		id:          "slow",
+		prices:      func(p rulesPrices) []int { return p.Slow },
+		icon:        8,
+		description: 8,
+		apply:       func(g *game, level int) { g.takeSlowStacks(level) },
+	},
+}
+
+// get the position of an improvement in gImprovements from its ID
+func findImprovement(id string) (int, bool) {
+	for pos, imp := range gImprovements {
+		if imp.id == id {
+			return pos, true
+		}
+	}
+	return -1, false
+}
+
+// IDs of all the improvements
+func improvementIDs() (ids []string) {
+	for _, imp := range gImprovements {
+		ids = append(ids, imp.id)
+	}
+	return
+}
+
 type improvements struct {
 	prices          [numImprove][]int
 	levels          [numImprove]int
-	current         int
+	current         int // improvement selected, numImprove for continue
+	page            int // page of the shop shown
 	arrowBlinkFrame int
 }
 
 func setupImprovements() (imp improvements) {
-	imp.prices[improveLife] = []int{10, 50, 150}
-	imp.prices[improveHold] = []int{150}
-	imp.prices[improveResetAutoDown] = []int{300}
-	imp.prices[improveHideMove] = []int{20, 75, 250}
+	imp.prices = gRules.improvementPrices()
 	return
 }
 
 func (i *improvements) reset() {
 	i.arrowBlinkFrame = 0
 	i.current = 0
-	for i.current != numImprove && i.levels[i.current] >= len(i.prices[i.current]) {
-		i.current = (i.current + 1) % (numImprove + 1)
+	i.page = 0
+	i.move(0)
+}
+
+// set the effects of the improvements on the current level
+func (g *game) applyImprovements() {
+	for id, imp := range gImprovements {
+		if imp.apply != nil {
+			imp.apply(g, g.improv.levels[id])
+		}
 	}
 }
 
-func drawMaxed(screen *ebiten.Image, x, y int) {
+// check if an improvement cannot be bought anymore
+func (i improvements) maxed(improvement int) bool {
+	return i.levels[improvement] >= len(i.prices[improvement])
+}
+
+// select the next improvement that can be bought in the given
+// direction (or the current one if step is 0), continue is
+// after the last improvement
+func (i *improvements) move(step int) {
+	if step != 0 {
+		i.current = (i.current + numImprove + 1 + step) % (numImprove + 1)
+	}
+	if step == 0 {
+		step = 1
+	}
+	for i.current != numImprove && i.maxed(i.current) {
+		i.current = (i.current + numImprove + 1 + step) % (numImprove + 1)
+	}
+	if i.current != numImprove {
+		i.page = i.current / shopPageSize
+	}
+}
+
+// number of pages of the shop
+func numShopPages() int {
+	return (numImprove + shopPageSize - 1) / shopPageSize
+}
+
+// icon of an improvement in assets.ImageImprovements
+func improvementIcon(improvement int) *ebiten.Image {
+	row := gImprovements[improvement].icon
+	return assets.ImageImprovements.SubImage(image.Rect(0, row*gImproveTextHeight, gImproveTextWidth, (row+1)*gImproveTextHeight)).(*ebiten.Image)
+}
+
+func drawMaxed(screen *ebiten.Image, x, y int, scaling float64) {
 	options := ebiten.DrawImageOptions{}
+	options.GeoM.Scale(scaling, scaling)
 	options.GeoM.Translate(float64(x), float64(y))
 	screen.DrawImage(assets.ImageMax, &options)
 }
 
 func drawArrow(screen *ebiten.Image, x, y int, rotate float64, blink int) {
+	drawScaledArrow(screen, x, y, rotate, blink, 1)
+}
+
+// same as drawArrow, with the arrow scaled by the given factor
+func drawScaledArrow(screen *ebiten.Image, x, y int, rotate float64, blink int, scaling float64) {
 	if blink < 2*numArrowBlinkFrame/3 {
 		options := ebiten.DrawImageOptions{}
+		options.GeoM.Scale(scaling, scaling)
 		options.GeoM.Rotate(rotate)
 		options.GeoM.Translate(float64(x), float64(y))
 		screen.DrawImage(assets.ImageImprovementsArrow, &options)
@@ -91,9 +238,10 @@ func drawContinue(screen *ebiten.Image, x, y int, selected bool, blink int) {
 
 func drawShopText(screen *ebiten.Image, x, y int, selection int) {
 	if selection < numImprove {
+		row := gImprovements[selection].description
 		options := ebiten.DrawImageOptions{}
 		options.GeoM.Translate(float64(x), float64(y))
-		screen.DrawImage(assets.ImageTextShop.SubImage(image.Rect(0, selection*gTextMalusHeight, gTextMalusWidth, (selection+1)*gTextMalusHeight)).(*ebiten.Image), &options)
+		screen.DrawImage(assets.ImageTextShop.SubImage(image.Rect(0, row*gTextMalusHeight, gTextMalusWidth, (row+1)*gTextMalusHeight)).(*ebiten.Image), &options)
 	}
 }
 
@@ -108,28 +256,45 @@ func (g game) drawStateImprove(screen *ebiten.Image) {
 
 	drawShopText(screen, (gWidth-gTextMalusWidth)/2, gHeight-gContinueHeight-gTitleMargin-gTextMalusHeight-gTitleMargin, g.improv.current)
 
-	x := (gWidth - (4*gImproveTextWidth + 3*xSeparator)) / 2
+	// the improvements are scaled down when a page does not fit in the screen
+	perPage := min(shopPageSize, numImprove)
+	scaling := math.Min(1, float64(gWidth-2*xSeparator)/float64(perPage*gImproveTextWidth+(perPage-1)*xSeparator))
+	width := int(float64(gImproveTextWidth) * scaling)
+	separator := int(float64(xSeparator) * scaling)
+
+	first := g.improv.page * shopPageSize
+	last := min(first+shopPageSize, numImprove)
+
+	x := (gWidth - ((last-first)*width + (last-first-1)*separator)) / 2
 	y := yStart
 
-	for i := 0; i < numImprove; i++ {
+	for i := first; i < last; i++ {
 
 		options := ebiten.DrawImageOptions{}
+		options.GeoM.Scale(scaling, scaling)
 		options.GeoM.Translate(float64(x), float64(y))
-		screen.DrawImage(assets.ImageImprovements.SubImage(image.Rect(0, i*gImproveTextHeight, gImproveTextWidth, (i+1)*gImproveTextWidth)).(*ebiten.Image), &options)
+		screen.DrawImage(improvementIcon(i), &options)
 
-		if i != numImprove {
-			if len(g.improv.prices[i]) > g.improv.levels[i] {
-				drawMoney(screen, x+3*gImproveTextWidth/5, y+gImproveTextHeight, g.improv.prices[i][g.improv.levels[i]], false, 0.4)
-			} else {
-				drawMaxed(screen, x+(gImproveTextWidth-gMaxWidth)/2, y+gImproveTextHeight-14)
-			}
+		if !g.improv.maxed(i) {
+			drawMoney(screen, x+3*width/5, y+width, g.improv.prices[i][g.improv.levels[i]], false, 0.4*scaling)
+		} else {
+			drawMaxed(screen, x+(width-int(float64(gMaxWidth)*scaling))/2, y+width-int(14*scaling), scaling)
 		}
 
 		if g.improv.current == i {
-			drawArrow(screen, x+(gImproveTextWidth-gArrowWidth)/2, y+gImproveTextHeight+40, 0, g.improv.arrowBlinkFrame)
+			drawArrow(screen, x+(width-gArrowWidth)/2, y+width+40, 0, g.improv.arrowBlinkFrame)
 		}
 
-		x += gImproveTextWidth + xSeparator
+		x += width + separator
+	}
+
+	// arrows on the sides when there are other pages
+	yMiddle := y + width/2 + gArrowWidth/2
+	if g.improv.page > 0 {
+		drawArrow(screen, xSeparator/2-gArrowHeight/2, yMiddle, -math.Pi/2, 0)
+	}
+	if g.improv.page < numShopPages()-1 {
+		drawArrow(screen, gWidth-xSeparator/2+gArrowHeight/2, yMiddle-gArrowWidth, math.Pi/2, 0)
 	}
 
 }
@@ -141,41 +306,34 @@ func (g *game) updateStateImprove() bool {
 		g.improv.arrowBlinkFrame = 0
 	}
 
-	if inpututil.IsKeyJustPressed(ebiten.KeyLeft) {
+	if g.input.isJustPressed(inputLeft) {
 		g.audio.NextSounds[assets.SoundMenuMoveID] = true
-		g.improv.current = (g.improv.current + numImprove) % (numImprove + 1)
-		for g.improv.current != numImprove && g.improv.levels[g.improv.current] >= len(g.improv.prices[g.improv.current]) {
-			g.improv.current = (g.improv.current + numImprove) % (numImprove + 1)
-		}
+		g.improv.move(-1)
 	}
 
-	if inpututil.IsKeyJustPressed(ebiten.KeyRight) {
+	if g.input.isJustPressed(inputRight) {
 		g.audio.NextSounds[assets.SoundMenuMoveID] = true
-		g.improv.current = (g.improv.current + 1) % (numImprove + 1)
-		for g.improv.current != numImprove && g.improv.levels[g.improv.current] >= len(g.improv.prices[g.improv.current]) {
-			g.improv.current = (g.improv.current + 1) % (numImprove + 1)
-		}
+		g.improv.move(1)
 	}
 
-	if inpututil.IsKeyJustPressed(ebiten.KeyDown) || inpututil.IsKeyJustPressed(ebiten.KeyUp) {
+	// from continue, go back to the first improvement of the page shown
+	if g.input.isJustPressed(inputDown) || g.input.isJustPressed(inputUp) {
 		g.audio.NextSounds[assets.SoundMenuMoveID] = true
 		if g.improv.current != numImprove {
 			g.improv.current = numImprove
 		} else {
-			g.improv.current = 0
-			for g.improv.current != numImprove && g.improv.levels[g.improv.current] >= len(g.improv.prices[g.improv.current]) {
-				g.improv.current = (g.improv.current + 1) % (numImprove + 1)
-			}
+			g.improv.current = g.improv.page * shopPageSize
+			g.improv.move(0)
 		}
 	}
 
-	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+	if g.input.isJustPressed(inputEnter) {
 		if g.improv.current == numImprove {
 			g.audio.NextSounds[assets.SoundMenuConfirmID] = true
 			return true
 		}
 
-		if g.improv.levels[g.improv.current] < len(g.improv.prices[g.improv.current]) {
+		if !g.improv.maxed(g.improv.current) {
 			if g.improv.prices[g.improv.current][g.improv.levels[g.improv.current]] <= g.money.money {
 				g.money.money -= g.improv.prices[g.improv.current][g.improv.levels[g.improv.current]]
 				g.improv.levels[g.improv.current]++
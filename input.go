@@ -0,0 +1,113 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// keys used by the game
+const (
+	inputLeft int = iota
+	inputRight
+	inputUp
+	inputDown
+	inputEnter
+	inputAlt
+	inputSpace
+	inputSlow
+	numInputs
+)
+
+var gInputKeys = [numInputs]ebiten.Key{
+	inputLeft:  ebiten.KeyLeft,
+	inputRight: ebiten.KeyRight,
+	inputUp:    ebiten.KeyUp,
+	inputDown:  ebiten.KeyDown,
+	inputEnter: ebiten.KeyEnter,
+	inputAlt:   ebiten.KeyAlt,
+	inputSpace: ebiten.KeySpace,
+	inputSlow:  ebiten.KeyShift,
+}
+
+// buttons of gamepads with a standard layout, used along with the keys
+var gInputButtons = [numInputs]ebiten.StandardGamepadButton{
+	inputLeft:  ebiten.StandardGamepadButtonLeftLeft,
+	inputRight: ebiten.StandardGamepadButtonLeftRight,
+	inputUp:    ebiten.StandardGamepadButtonLeftTop,
+	inputDown:  ebiten.StandardGamepadButtonLeftBottom,
+	inputEnter: ebiten.StandardGamepadButtonRightBottom,
+	inputAlt:   ebiten.StandardGamepadButtonRightLeft,
+	inputSpace: ebiten.StandardGamepadButtonRightRight,
+	inputSlow:  ebiten.StandardGamepadButtonRightTop,
+}
+
+// state of the keys for one frame, all the game reads its
+// inputs from there so that they can be recorded and replayed
+type inputState struct {
+	pressed     [numInputs]bool
+	justPressed [numInputs]bool
+}
+
+func readInput() (i inputState) {
+	for input, key := range gInputKeys {
+		i.pressed[input] = ebiten.IsKeyPressed(key)
+		i.justPressed[input] = inpututil.IsKeyJustPressed(key)
+	}
+	for _, id := range ebiten.AppendGamepadIDs(nil) {
+		if !ebiten.IsStandardGamepadLayoutAvailable(id) {
+			continue
+		}
+		for input, button := range gInputButtons {
+			i.pressed[input] = i.pressed[input] || ebiten.IsStandardGamepadButtonPressed(id, button)
+			i.justPressed[input] = i.justPressed[input] || inpututil.IsStandardGamepadButtonJustPressed(id, button)
+		}
+	}
+	return
+}
+
+func (i inputState) isPressed(input int) bool {
+	return i.pressed[input]
+}
+
+func (i inputState) isJustPressed(input int) bool {
+	return i.justPressed[input]
+}
+
+// pack the state of the keys in an integer, for replays
+func (i inputState) encode() (code uint32) {
+	for input := 0; input < numInputs; input++ {
+		if i.pressed[input] {
+			code |= 1 << (2 * input)
+		}
+		if i.justPressed[input] {
+			code |= 1 << (2*input + 1)
+		}
+	}
+	return
+}
+
+func decodeInput(code uint32) (i inputState) {
+	for input := 0; input < numInputs; input++ {
+		i.pressed[input] = code&(1<<(2*input)) != 0
+		i.justPressed[input] = code&(1<<(2*input+1)) != 0
+	}
+	return
+}
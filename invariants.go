@@ -0,0 +1,134 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import "fmt"
+
+// check that the state of a tetris game is consistent, this is
+// only run at each update when the game is built with -tags debug
+func (t tetris) checkInvariants() error {
+
+	if err := checkGrid(t.area); err != nil {
+		return err
+	}
+
+	if t.dead {
+		return nil
+	}
+
+	for _, pos := range blockSquares(t.currentBlock) {
+		if pos[0] < 0 || pos[0] >= gPlayAreaWidthInBlocks ||
+			pos[1] < 0 || pos[1] >= len(t.area) {
+			return fmt.Errorf("active piece out of the grid at (%d, %d)", pos[0], pos[1])
+		}
+	}
+
+	if !t.inAnimation {
+		for y, line := range t.area {
+			if isLineComplete(line) {
+				return fmt.Errorf("line %d is complete but was not removed", y)
+			}
+		}
+	}
+
+	return nil
+}
+
+// check that a grid only contains known styles and that it has
+// no floating cells, which means that no empty line can be found
+// below a non-empty one since lines are moved as a whole (walls do
+// not make a line non-empty)
+func checkGrid(grid tetrisGrid) error {
+
+	nonEmptySeen := -1
+
+	for y, line := range grid {
+		empty := true
+		for x, style := range line {
+			if style < noStyle || style >= numStyles || style == breakStyle {
+				return fmt.Errorf("unexpected style %d at (%d, %d)", style, x, y)
+			}
+			if style != noStyle && style != wallStyle {
+				empty = false
+			}
+		}
+		if empty && nonEmptySeen >= 0 {
+			return fmt.Errorf("line %d is empty but line %d above it is not", y, nonEmptySeen)
+		}
+		if !empty && nonEmptySeen < 0 {
+			nonEmptySeen = y
+		}
+	}
+
+	return nil
+}
+
+// check that removing numLines lines from before gave after
+func checkRemoval(before, after tetrisGrid, numLines int) error {
+
+	removed := 0
+	for _, line := range before {
+		if isLineComplete(line) {
+			removed++
+		}
+	}
+	if removed != numLines {
+		return fmt.Errorf("%d complete lines but %d counted", removed, numLines)
+	}
+
+	removedCells := 0
+	for _, line := range before {
+		if isLineComplete(line) {
+			removedCells += countLineCells(line)
+		}
+	}
+
+	cellsBefore, cellsAfter := countCells(before), countCells(after)
+	if cellsBefore-removedCells != cellsAfter {
+		return fmt.Errorf("%d cells before removing %d lines but %d after", cellsBefore, numLines, cellsAfter)
+	}
+
+	return checkGrid(after)
+}
+
+func isLineComplete(line tetrisLine) bool {
+	for _, style := range line {
+		if style == noStyle {
+			return false
+		}
+	}
+	return true
+}
+
+// number of squares in a grid, walls excluded
+func countCells(grid tetrisGrid) (count int) {
+	for _, line := range grid {
+		count += countLineCells(line)
+	}
+	return
+}
+
+func countLineCells(line tetrisLine) (count int) {
+	for _, style := range line {
+		if style != noStyle && style != wallStyle {
+			count++
+		}
+	}
+	return
+}
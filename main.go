@@ -19,18 +19,44 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/loig/ebitenginegamejam2024/assets"
 )
 
 func main() {
 
+	if len(os.Args) > 1 && os.Args[1] == "simulate" {
+		os.Exit(runSimulate(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "offers" {
+		os.Exit(runOffers(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
+	opts, err := parseOptions(os.Args[1:], os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		os.Exit(2)
+	}
+
 	g := game{}
-	g.init()
+	if err := g.init(opts); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
 	ebiten.SetWindowTitle("Yet Another Tetris Clone")
 	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
-	//ebiten.SetWindowSize(640, 576)
+	if opts.Scale > 0 {
+		ebiten.SetWindowSize(int(float64(gWidth)*opts.Scale), int(float64(gHeight)*opts.Scale))
+	}
+	ebiten.SetFullscreen(opts.Fullscreen)
 
 	assets.Load(gMultFactor)
 
@@ -0,0 +1,314 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/loig/ebitenginegamejam2024/assets"
+)
+
+// Malus is a handicap that the player chooses between levels, each
+// time it is chosen its level increases, up to its max level.
+type Malus interface {
+	ID() string                 // name used on the command line and in the console
+	MaxLevel() int              // highest level of the malus
+	Icon() *ebiten.Image        // icon displayed in the balancing wheel
+	Description() *ebiten.Image // explanation displayed in the balancing wheel
+	Apply(t *tetris, level int) // set the effects of the malus on a game, level may be 0
+}
+
+// Malus whose effect on a game can be given as a single number,
+// so that the balancing wheel can show what a choice changes.
+type valuedMalus interface {
+	Value(t tetris) (value int, on bool) // number set by Apply in t, on is false when it has no effect
+}
+
+// all the maluses, their positions in this list are
+// used for identifying them in the balancing state
+var gMaluses = []Malus{
+	goalLinesMalus{},
+	speedMalus{},
+	hiddenLinesMalus{},
+	deathLinesMalus{},
+	invisibleBlocksMalus{},
+	mirrorMalus{},
+	riseMalus{},
+	previewMalus{},
+	biasMalus{},
+	wallsMalus{},
+	windMalus{},
+	rotationMalus{},
+	timeMalus{},
+	darknessMalus{},
+}
+
+const (
+	maxLevelGoalLines       = 2
+	maxLevelSpeed           = 5
+	maxLevelHiddenLines     = 5
+	maxLevelDeathLines      = 5
+	maxLevelInvisibleBlocks = 3
+	maxLevelMirror          = 3
+	maxLevelRise            = 4
+	maxLevelPreview         = previewHidden
+	maxLevelBias            = 3
+	maxLevelWalls           = 3
+	maxLevelWind            = 3
+	maxLevelRotation        = rotationTwoStates
+	maxLevelTime            = 3
+	maxLevelDarkness        = 3
+)
+
+// column of assets.ImageMalus that highlights the current choice
+const malusSelectedIcon int = 5
+
+// column of assets.ImageMalus and row of assets.ImageTextMalus for the skip token
+const (
+	malusSkipIcon        int = 15
+	malusSkipDescription int = 14
+)
+
+// row of assets.ImageTextMalus for the cleansing of a malus
+const malusCleanseDescription int = 19
+
+// get the position of a malus in gMaluses from its ID
+func findMalus(id string) (int, bool) {
+	for pos, m := range gMaluses {
+		if m.ID() == id {
+			return pos, true
+		}
+	}
+	return -1, false
+}
+
+// IDs of all the maluses
+func malusIDs() (ids []string) {
+	for _, m := range gMaluses {
+		ids = append(ids, m.ID())
+	}
+	return
+}
+
+// icon of a malus in assets.ImageMalus
+func malusIcon(column int) *ebiten.Image {
+	return assets.ImageMalus.SubImage(image.Rect(column*gChoiceSize, 0, (column+1)*gChoiceSize, gChoiceSize)).(*ebiten.Image)
+}
+
+// explanation of a malus in assets.ImageTextMalus
+func malusDescription(row int) *ebiten.Image {
+	return assets.ImageTextMalus.SubImage(image.Rect(0, row*gTextMalusHeight, gTextMalusWidth, (row+1)*gTextMalusHeight)).(*ebiten.Image)
+}
+
+// more lines are needed for completing a level
+type goalLinesMalus struct{}
+
+func (goalLinesMalus) ID() string                 { return "goal" }
+func (goalLinesMalus) MaxLevel() int              { return maxLevelGoalLines }
+func (goalLinesMalus) Icon() *ebiten.Image        { return malusIcon(0) }
+func (goalLinesMalus) Description() *ebiten.Image { return malusDescription(0) }
+
+func (goalLinesMalus) Apply(t *tetris, level int) {
+	t.goalLines = ruleAt(gRules.GoalLines, level)
+}
+
+func (goalLinesMalus) Value(t tetris) (int, bool) { return t.goalLines, true }
+
+// tetrominoes fall faster
+type speedMalus struct{}
+
+func (speedMalus) ID() string                 { return "speed" }
+func (speedMalus) MaxLevel() int              { return maxLevelSpeed }
+func (speedMalus) Icon() *ebiten.Image        { return malusIcon(1) }
+func (speedMalus) Description() *ebiten.Image { return malusDescription(1) }
+
+func (speedMalus) Apply(t *tetris, level int) {
+	t.autoDownFrameLimit = gRules.Speeds[speedLevelAt(t.speedLevel, level)]
+}
+
+// frames for falling one row
+func (speedMalus) Value(t tetris) (int, bool) { return t.autoDownFrameLimit, true }
+
+// speed level used when the speed malus is at a given level
+func speedLevelAt(baseSpeedLevel, level int) int {
+	baseSpeedLevel += ruleAt(gRules.SpeedLevels, level)
+
+	if baseSpeedLevel >= len(gRules.Speeds) {
+		baseSpeedLevel = len(gRules.Speeds) - 1
+	}
+
+	return baseSpeedLevel
+}
+
+// fog hides the bottom of the play area
+type hiddenLinesMalus struct{}
+
+func (hiddenLinesMalus) ID() string                 { return "hidden" }
+func (hiddenLinesMalus) MaxLevel() int              { return maxLevelHiddenLines }
+func (hiddenLinesMalus) Icon() *ebiten.Image        { return malusIcon(2) }
+func (hiddenLinesMalus) Description() *ebiten.Image { return malusDescription(2) }
+
+func (hiddenLinesMalus) Apply(t *tetris, level int) {
+	t.hiddenLines = ruleAt(gRules.HiddenLines, level)
+}
+
+func (hiddenLinesMalus) Value(t tetris) (int, bool) { return t.hiddenLines, true }
+
+// the danger zone at the top of the play area grows
+type deathLinesMalus struct{}
+
+func (deathLinesMalus) ID() string                 { return "death" }
+func (deathLinesMalus) MaxLevel() int              { return maxLevelDeathLines }
+func (deathLinesMalus) Icon() *ebiten.Image        { return malusIcon(3) }
+func (deathLinesMalus) Description() *ebiten.Image { return malusDescription(3) }
+
+func (deathLinesMalus) Apply(t *tetris, level int) {
+	t.deathLines = ruleAt(gRules.DeathLines, level)
+}
+
+func (deathLinesMalus) Value(t tetris) (int, bool) { return t.deathLines, true }
+
+// falling tetrominoes are invisible part of the time
+type invisibleBlocksMalus struct{}
+
+func (invisibleBlocksMalus) ID() string                 { return "invisible" }
+func (invisibleBlocksMalus) MaxLevel() int              { return maxLevelInvisibleBlocks }
+func (invisibleBlocksMalus) Icon() *ebiten.Image        { return malusIcon(4) }
+func (invisibleBlocksMalus) Description() *ebiten.Image { return malusDescription(4) }
+
+func (invisibleBlocksMalus) Apply(t *tetris, level int) {
+	t.invisibleLevel = level
+}
+
+// left and right, and the rotations, are swapped from time to time
+type mirrorMalus struct{}
+
+func (mirrorMalus) ID() string                 { return "mirror" }
+func (mirrorMalus) MaxLevel() int              { return maxLevelMirror }
+func (mirrorMalus) Icon() *ebiten.Image        { return malusIcon(6) }
+func (mirrorMalus) Description() *ebiten.Image { return malusDescription(5) }
+
+func (mirrorMalus) Apply(t *tetris, level int) {
+	t.mirrorLevel = level
+}
+
+// lines of garbage rise from the bottom of the play area
+type riseMalus struct{}
+
+func (riseMalus) ID() string                 { return "rise" }
+func (riseMalus) MaxLevel() int              { return maxLevelRise }
+func (riseMalus) Icon() *ebiten.Image        { return malusIcon(7) }
+func (riseMalus) Description() *ebiten.Image { return malusDescription(6) }
+
+func (riseMalus) Apply(t *tetris, level int) {
+	t.risePieces = ruleAt(gRules.RisePieces, level)
+}
+
+// pieces between two garbage rows, the floor does not rise with 0
+func (riseMalus) Value(t tetris) (int, bool) { return t.risePieces, t.risePieces > 0 }
+
+// the preview of the next piece is not reliable
+type previewMalus struct{}
+
+func (previewMalus) ID() string                 { return "preview" }
+func (previewMalus) MaxLevel() int              { return maxLevelPreview }
+func (previewMalus) Icon() *ebiten.Image        { return malusIcon(8) }
+func (previewMalus) Description() *ebiten.Image { return malusDescription(7) }
+
+func (previewMalus) Apply(t *tetris, level int) {
+	t.previewLevel = level
+}
+
+// bad luck: S and Z pieces come more often, I pieces less often
+type biasMalus struct{}
+
+func (biasMalus) ID() string                 { return "bias" }
+func (biasMalus) MaxLevel() int              { return maxLevelBias }
+func (biasMalus) Icon() *ebiten.Image        { return malusIcon(9) }
+func (biasMalus) Description() *ebiten.Image { return malusDescription(8) }
+
+func (biasMalus) Apply(t *tetris, level int) {
+	t.biasLevel = level
+}
+
+// walls take the place of columns on the sides of the play area
+type wallsMalus struct{}
+
+func (wallsMalus) ID() string                 { return "walls" }
+func (wallsMalus) MaxLevel() int              { return maxLevelWalls }
+func (wallsMalus) Icon() *ebiten.Image        { return malusIcon(10) }
+func (wallsMalus) Description() *ebiten.Image { return malusDescription(9) }
+
+func (wallsMalus) Apply(t *tetris, level int) {
+	t.wallLevel = level
+}
+
+// gusts of wind push the falling piece to the side
+type windMalus struct{}
+
+func (windMalus) ID() string                 { return "wind" }
+func (windMalus) MaxLevel() int              { return maxLevelWind }
+func (windMalus) Icon() *ebiten.Image        { return malusIcon(11) }
+func (windMalus) Description() *ebiten.Image { return malusDescription(10) }
+
+func (windMalus) Apply(t *tetris, level int) {
+	t.windLevel = level
+}
+
+// rotations are restricted and need time to recover
+type rotationMalus struct{}
+
+func (rotationMalus) ID() string                 { return "spin" }
+func (rotationMalus) MaxLevel() int              { return maxLevelRotation }
+func (rotationMalus) Icon() *ebiten.Image        { return malusIcon(12) }
+func (rotationMalus) Description() *ebiten.Image { return malusDescription(11) }
+
+func (rotationMalus) Apply(t *tetris, level int) {
+	t.rotationLevel = level
+}
+
+// levels must be completed before the end of a countdown
+type timeMalus struct{}
+
+func (timeMalus) ID() string                 { return "time" }
+func (timeMalus) MaxLevel() int              { return maxLevelTime }
+func (timeMalus) Icon() *ebiten.Image        { return malusIcon(13) }
+func (timeMalus) Description() *ebiten.Image { return malusDescription(12) }
+
+func (timeMalus) Apply(t *tetris, level int) {
+	t.timeLimit = ruleAt(gRules.TimeLimits, level) * timeFramesPerSecond
+}
+
+// seconds for completing the level, there is no limit with 0
+func (timeMalus) Value(t tetris) (int, bool) {
+	return t.timeLimit / timeFramesPerSecond, t.timeLimit > 0
+}
+
+// the play area is dark except around the falling piece
+type darknessMalus struct{}
+
+func (darknessMalus) ID() string                 { return "dark" }
+func (darknessMalus) MaxLevel() int              { return maxLevelDarkness }
+func (darknessMalus) Icon() *ebiten.Image        { return malusIcon(14) }
+func (darknessMalus) Description() *ebiten.Image { return malusDescription(13) }
+
+func (darknessMalus) Apply(t *tetris, level int) {
+	t.darknessLevel = level
+}
@@ -0,0 +1,101 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	mirrorWarningFrames int     = 180 // the flip is announced 3 seconds before
+	mirrorBlinkFrames   int     = 15  // blinking speed of the warning
+	mirrorBorderWidth   float32 = 12  // width of the border around the play area
+)
+
+var (
+	mirrorWarningColor = color.RGBA{243, 164, 13, 255}
+	mirrorActiveColor  = color.RGBA{246, 132, 241, 255}
+)
+
+// swap left and right, and the two rotations
+func (i inputState) mirrored() inputState {
+	i.pressed[inputLeft], i.pressed[inputRight] = i.pressed[inputRight], i.pressed[inputLeft]
+	i.justPressed[inputLeft], i.justPressed[inputRight] = i.justPressed[inputRight], i.justPressed[inputLeft]
+	i.pressed[inputAlt], i.pressed[inputSpace] = i.pressed[inputSpace], i.pressed[inputAlt]
+	i.justPressed[inputAlt], i.justPressed[inputSpace] = i.justPressed[inputSpace], i.justPressed[inputAlt]
+	return i
+}
+
+// number of frames before the controls flip
+func (t tetris) mirrorFramesLeft() int {
+	if t.mirrored {
+		return ruleAt(gRules.MirroredFrames, t.mirrorLevel-1) - t.mirrorFrame
+	}
+	return ruleAt(gRules.MirrorFrames, t.mirrorLevel-1) - t.mirrorFrame
+}
+
+func (t *tetris) updateMirror() {
+	if t.mirrorLevel <= 0 {
+		t.mirrored = false
+		return
+	}
+	t.mirrorFrame++
+	if t.mirrorFramesLeft() <= 0 {
+		t.mirrored = !t.mirrored
+		t.mirrorFrame = 0
+	}
+}
+
+// border around the play area, blinking before a flip
+// and steady while the controls are mirrored
+func (t tetris) drawMirror(screen *ebiten.Image, gray uint8) {
+	if t.mirrorLevel <= 0 {
+		return
+	}
+
+	warning := t.mirrorFramesLeft() <= mirrorWarningFrames
+	if warning && (t.mirrorFramesLeft()/mirrorBlinkFrames)%2 == 0 {
+		return
+	}
+	if !warning && !t.mirrored {
+		return
+	}
+
+	c := mirrorActiveColor
+	if warning {
+		c = mirrorWarningColor
+	}
+
+	vector.StrokeRect(screen,
+		float32(gPlayAreaSide)+mirrorBorderWidth/2, mirrorBorderWidth/2,
+		float32(gPlayAreaWidth)-mirrorBorderWidth, float32(gPlayAreaHeight)-mirrorBorderWidth,
+		mirrorBorderWidth, grayColor(c, gray), false)
+
+	if warning {
+		options := ebiten.DrawImageOptions{}
+		options.ColorScale.ScaleWithColor(color.Gray{gray})
+		options.GeoM.Scale(0.5, 0.5)
+		options.GeoM.Translate(float64(gPlayAreaSide+(gPlayAreaWidth-gChoiceSize/2)/2), float64(gPlayAreaHeight-gChoiceSize/2)/2)
+		options.ColorScale.ScaleAlpha(0.7)
+		screen.DrawImage(mirrorMalus{}.Icon(), &options)
+	}
+}
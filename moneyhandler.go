@@ -22,12 +22,10 @@ import (
 	"image"
 
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/loig/ebitenginegamejam2024/assets"
 )
 
 const (
-	scoreToMoney             int = 100
 	scoreUnitPerFrame        int = 5
 	scoreCountStep           int = 3
 	scoreCoinAnimationFrames int = 30
@@ -44,6 +42,7 @@ type moneyHandler struct {
 	coins              []coinAnimator
 	firstAvailableCoin int
 	numActive          int
+	bonus              int // percentage of the score added at the next payout
 }
 
 type coinAnimator struct {
@@ -102,9 +101,11 @@ func (c coinAnimator) draw(screen *ebiten.Image) {
 }
 
 func (m *moneyHandler) addScore(score int) {
+	score += score * m.bonus / 100
+	m.bonus = 0
 	m.displayMoney = m.money
 	m.previousMoney = m.money
-	m.money += score / scoreToMoney
+	m.money += score / gRules.ScoreToMoney
 	m.score = score
 	m.count = 0
 	m.nextCoin = 0
@@ -116,7 +117,7 @@ func (m *moneyHandler) addScore(score int) {
 	m.numActive = 0
 }
 
-func (m *moneyHandler) update() (finished bool, playSounds [assets.NumSounds]bool) {
+func (m *moneyHandler) update(input inputState) (finished bool, playSounds [assets.NumSounds]bool) {
 
 	if m.score > 0 {
 		if m.score < m.scoreReduction {
@@ -164,7 +165,7 @@ func (m *moneyHandler) update() (finished bool, playSounds [assets.NumSounds]boo
 		}
 	}
 
-	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+	if input.isJustPressed(inputEnter) {
 		if m.score <= 0 {
 			playSounds[assets.SoundMenuConfirmID] = m.numActive <= 0
 			return m.numActive <= 0, playSounds
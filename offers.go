@@ -0,0 +1,210 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Tool for tuning the weighting of the maluses offered between
+// levels: for each combination of malus levels given on the command
+// line, many successive offers are drawn from a fixed seed (so the
+// results are always the same), and the frequency of each malus in
+// the offers is written as CSV. The guarantees on the offers are
+// checked by the tests in offers_test.go.
+//
+//	yatc offers -sets 10000 -malus speed=4:5 -level 3 -o offers.csv
+
+// frequencies over the offers of one configuration
+type offerStats struct {
+	levels   []int
+	offered  []float64 // frequency of each malus in the offers
+	nearOnly float64   // frequency of offers with only near-maxed maluses while others could be offered
+}
+
+// entry point of the offers command, returns the exit code
+func runOffers(args []string, stdout, stderr io.Writer) int {
+
+	var maluses simSweeps
+	var sets, numChoices, level int
+	var seed int64
+	var outPath, rulesPath string
+
+	fs := flag.NewFlagSet("yatc offers", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: yatc offers [options]")
+		fs.PrintDefaults()
+		fmt.Fprintln(stderr, "maluses:", strings.Join(malusIDs(), ", "))
+	}
+	fs.Var(&maluses, "malus", "malus levels to try, as name=min:max or name=v1,v2,... (repeatable)")
+	fs.IntVar(&sets, "sets", 10000, "number of successive offers for each configuration")
+	fs.Int64Var(&seed, "seed", 1, "seed of the random generator")
+	fs.IntVar(&numChoices, "choices", 0, "number of maluses in an offer (0 for the value of the rules)")
+	fs.IntVar(&level, "level", 0, "level after which the offers are made (0 for the first offer)")
+	fs.StringVar(&outPath, "o", "", "output file (standard output by default)")
+	fs.StringVar(&rulesPath, "rules", "", "JSON file for tuning the rules of the game")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	usageError := func(err error) int {
+		fmt.Fprintln(stderr, err)
+		fs.Usage()
+		return 2
+	}
+
+	if rulesPath != "" {
+		var err error
+		if gRules, err = loadRules(rulesPath); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+	if numChoices == 0 {
+		numChoices = gRules.NumChoices
+	}
+
+	if fs.NArg() > 0 {
+		return usageError(fmt.Errorf("unexpected argument %q", fs.Arg(0)))
+	}
+	if sets < 1 {
+		return usageError(fmt.Errorf("sets: must be at least 1, got %d", sets))
+	}
+	if numChoices < 1 || numChoices > len(gMaluses) {
+		return usageError(fmt.Errorf("choices: must be between 1 and %d, got %d", len(gMaluses), numChoices))
+	}
+	if level < 0 {
+		return usageError(fmt.Errorf("level: must be at least 0, got %d", level))
+	}
+
+	configs, err := simConfigs(maluses, nil)
+	if err != nil {
+		return usageError(err)
+	}
+
+	out := stdout
+	if outPath != "" {
+		file, err := os.Create(outPath)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		defer file.Close()
+		out = file
+	}
+
+	stats := make([]offerStats, 0, len(configs))
+	for _, config := range configs {
+		stats = append(stats, offerFrequencies(config.levels, sets, seed, numChoices, level))
+	}
+
+	if err := writeOffersCSV(out, stats); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+// draw successive offers with fixed malus levels
+func offerFrequencies(levels []int, sets int, seed int64, numChoices, level int) (stats offerStats) {
+
+	seedRandom(seed)
+
+	b := newBalance(numChoices, 0)
+	copy(b.levels, levels)
+
+	freshAvailable := false
+	for id := range gMaluses {
+		if b.offerWeight(id, level) > 0 && !b.nearMaxed(id) {
+			freshAvailable = true
+		}
+	}
+
+	stats.levels = levels
+	stats.offered = make([]float64, len(gMaluses))
+
+	for set := 0; set < sets; set++ {
+		b.getChoice(level, false)
+		fresh := false
+		for _, id := range b.choices[:b.numChoices] {
+			if id < 0 {
+				continue
+			}
+			stats.offered[id]++
+			fresh = fresh || !b.nearMaxed(id)
+		}
+		if freshAvailable && !fresh {
+			stats.nearOnly++
+		}
+	}
+
+	for id := range stats.offered {
+		stats.offered[id] /= float64(sets)
+	}
+	stats.nearOnly /= float64(sets)
+
+	return
+}
+
+func writeOffersCSV(out io.Writer, stats []offerStats) error {
+	w := csv.NewWriter(out)
+
+	header := malusIDs()
+	for _, name := range malusIDs() {
+		header = append(header, "offered_"+name)
+	}
+	header = append(header, "near_maxed_only")
+	if err := w.Write(header); err != nil {
+		return err
+	}
+
+	format := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', 4, 64)
+	}
+
+	for _, s := range stats {
+		record := make([]string, 0, len(header))
+		for _, level := range s.levels {
+			record = append(record, strconv.Itoa(level))
+		}
+		for _, freq := range s.offered {
+			record = append(record, format(freq))
+		}
+		record = append(record, format(s.nearOnly))
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
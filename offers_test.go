@@ -0,0 +1,114 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import "testing"
+
+// malus levels from levels given by malus ID, the others at level
+// base, or at their max level for a negative base
+func offerLevels(base int, levels map[string]int) []int {
+	all := make([]int, len(gMaluses))
+	for id, m := range gMaluses {
+		all[id] = base
+		if base < 0 {
+			all[id] = m.MaxLevel()
+		}
+	}
+	for name, level := range levels {
+		id, found := findMalus(name)
+		if !found {
+			panic("unknown malus " + name)
+		}
+		all[id] = level
+	}
+	return all
+}
+
+func TestOfferFrequencies(t *testing.T) {
+	tests := []struct {
+		name       string
+		levels     []int
+		numChoices int
+		level      int
+	}{
+		{"fresh", offerLevels(0, nil), 3, 3},
+		{"one fresh", offerLevels(-1, map[string]int{"speed": maxLevelSpeed - 1, "hidden": maxLevelHiddenLines - 1, "goal": 0}), 3, 3},
+		{"some maxed", offerLevels(0, map[string]int{"speed": maxLevelSpeed, "hidden": maxLevelHiddenLines, "death": maxLevelDeathLines}), 3, 3},
+		{"near-maxed only", offerLevels(-1, map[string]int{"mirror": maxLevelMirror - 1}), 3, 3},
+		{"first offer", offerLevels(1, map[string]int{"walls": maxLevelWalls}), 4, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stats := offerFrequencies(test.levels, 2000, 1, test.numChoices, test.level)
+			if stats.nearOnly != 0 {
+				t.Errorf("only near-maxed maluses in %.4f of the offers while a fresh one could be offered", stats.nearOnly)
+			}
+			offered := 0
+			for id, freq := range stats.offered {
+				if test.levels[id] >= gMaluses[id].MaxLevel() && freq > 0 {
+					t.Errorf("maxed malus %s offered in %.4f of the offers", gMaluses[id].ID(), freq)
+				}
+				if freq > 0 {
+					offered++
+				}
+			}
+			if offered == 0 {
+				t.Error("no malus offered")
+			}
+		})
+	}
+}
+
+func TestOfferPity(t *testing.T) {
+	saved := gRules
+	defer func() { gRules = saved }()
+
+	gRules.Offers.Weights = map[string]int{"speed": 10}
+	levels := offerLevels(0, nil)
+	speed, _ := findMalus("speed")
+
+	gRules.Offers.Pity = 0
+	without := offerFrequencies(levels, 2000, 1, 3, 3).offered[speed]
+
+	gRules.Offers.Pity = 2
+	gRules.Offers.PityGrowth = 100
+	with := offerFrequencies(levels, 2000, 1, 3, 3).offered[speed]
+
+	if with <= without {
+		t.Errorf("speed offered in %.4f of the offers with pity, %.4f without", with, without)
+	}
+}
+
+func TestOfferEmpty(t *testing.T) {
+	saved := gRules
+	defer func() { gRules = saved }()
+	gRules.CleanseChance = 0
+
+	for _, skipChance := range []int{0, 100} {
+		b := newBalance(3, 0)
+		b.skipChance = skipChance
+		copy(b.levels, offerLevels(-1, nil))
+		b.getChoice(3, false)
+
+		if b.numChoices != 1 || !b.skipSelected() || b.skipCost() != 0 {
+			t.Errorf("offer %v with all maluses maxed and skip chance %d, instead of a free skip", b.choices[:b.numChoices], skipChance)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import "github.com/hajimehoshi/ebiten/v2"
+
+// levels of the preview corruption malus
+const (
+	previewFlicker int = 1 // the preview is off from time to time
+	previewWrong   int = 2 // the preview may show a wrong piece
+	previewHidden  int = 3 // the preview is never shown
+)
+
+const (
+	previewMinOnFrames  int = 60  // shortest time the preview stays on
+	previewMaxOnFrames  int = 180 // longest time the preview stays on
+	previewMinOffFrames int = 20  // shortest time the preview stays off
+	previewMaxOffFrames int = 50  // longest time the preview stays off
+	previewWrongChances int = 3   // one piece out of this is shown wrong
+)
+
+// switch the preview on and off
+func (t *tetris) updatePreview() {
+	if t.previewLevel < previewFlicker {
+		return
+	}
+	t.previewFrame--
+	if t.previewFrame <= 0 {
+		t.previewOff = !t.previewOff
+		if t.previewOff {
+			t.previewFrame = previewMinOffFrames + gRandom.Intn(previewMaxOffFrames-previewMinOffFrames+1)
+		} else {
+			t.previewFrame = previewMinOnFrames + gRandom.Intn(previewMaxOnFrames-previewMinOnFrames+1)
+		}
+	}
+}
+
+// choose what the preview shows for the current next piece
+func (t *tetris) pickPreview() {
+	t.previewBlock = tetrisBlock{id: -1}
+	if t.previewLevel < previewWrong || gRandom.Intn(previewWrongChances) != 0 {
+		return
+	}
+	for t.previewBlock.id < 0 || t.previewBlock.style == t.nextBlock.style {
+		t.previewBlock, _ = getBlockOfStyle(iBlockStyle + gRandom.Intn(zBlockStyle))
+	}
+}
+
+// draw the next piece as the preview corruption allows it
+func (t tetris) drawNext(screen *ebiten.Image, gray uint8, x, y int) {
+	if t.previewLevel >= previewHidden || t.previewOff {
+		return
+	}
+	block := t.nextBlock
+	if t.previewBlock.id >= 0 {
+		block = t.previewBlock
+	}
+	block.draw(screen, gray, x, y, 1)
+}
@@ -0,0 +1,40 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// all the randomness of the game goes through gRandom so
+// that a run can be reproduced from its seed
+var (
+	gSeed   int64
+	gRandom *rand.Rand
+)
+
+func init() {
+	seedRandom(time.Now().UnixNano())
+}
+
+func seedRandom(seed int64) {
+	gSeed = seed
+	gRandom = rand.New(rand.NewSource(seed))
+}
@@ -0,0 +1,142 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+)
+
+// record of a run: its setup and the inputs for each frame
+// from its start, which is enough for playing it again
+type replay struct {
+	Seed         int64           `json:"seed"`
+	Mode         string          `json:"mode"`
+	Level        int             `json:"level"`
+	GoalLevel    int             `json:"goal"`
+	NumChoices   int             `json:"choices"`
+	Tier         int             `json:"tier"`
+	Improvements [numImprove]int `json:"improvements"`
+	Money        int             `json:"money"` // coins at the start, for rerolls and skip tokens
+	Rules        rules           `json:"rules"`
+	Inputs       []uint32        `json:"inputs"`
+}
+
+func loadReplay(path string) (r replay, err error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return r, fmt.Errorf("replay: %w", err)
+	}
+	r.Rules = defaultRules()
+	if err = json.Unmarshal(content, &r); err != nil {
+		return r, fmt.Errorf("replay %s: %w", path, err)
+	}
+	if err = r.Rules.validate(); err != nil {
+		return r, fmt.Errorf("replay %s:\n%w", path, err)
+	}
+	prices := r.Rules.improvementPrices()
+	if r.NumChoices < 1 || r.NumChoices > len(gMaluses) || r.Level < 0 || r.Money < 0 || r.Tier < 0 || r.Tier > len(r.Rules.Tiers) {
+		return r, fmt.Errorf("replay %s: invalid setup", path)
+	}
+	for i, level := range r.Improvements {
+		if level < 0 || level > len(prices[i]) {
+			return r, fmt.Errorf("replay %s: invalid improvement level %d", path, level)
+		}
+	}
+	return r, nil
+}
+
+func (r replay) save(path string) error {
+	content, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("replay: %w", err)
+	}
+	if err = os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("replay: %w", err)
+	}
+	return nil
+}
+
+// get the inputs for the current frame, from the replay being
+// played if any, and record them if a run is being recorded
+func (g *game) updateInput() {
+
+	g.input = readInput()
+
+	if g.replaying != nil {
+		if g.replayFrame < len(g.replaying.Inputs) {
+			g.input = decodeInput(g.replaying.Inputs[g.replayFrame])
+			g.replayFrame++
+		} else {
+			g.replaying = nil
+		}
+	}
+
+	if g.recording != nil {
+		g.recording.Inputs = append(g.recording.Inputs, g.input.encode())
+	}
+}
+
+// start recording a run that begins with the given seed
+func (g *game) startRecording(seed int64) {
+	g.recording = &replay{
+		Seed:         seed,
+		Mode:         g.mode,
+		Level:        g.level,
+		GoalLevel:    g.goalLevel,
+		NumChoices:   g.numChoices,
+		Tier:         g.tier,
+		Improvements: g.improv.levels,
+		Money:        g.money.money,
+		Rules:        gRules,
+	}
+}
+
+// save the recorded run, only one run is recorded
+func (g *game) stopRecording() {
+	if g.recording == nil {
+		return
+	}
+	if err := g.recording.save(g.recordPath); err != nil {
+		log.Print(err)
+	}
+	g.recording = nil
+	g.recordPath = ""
+}
+
+// play a recorded run from its start
+func (g *game) startReplay(r replay) {
+	g.mode = r.Mode
+	g.level = r.Level
+	g.firstLevel = r.Level
+	g.goalLevel = r.GoalLevel
+	g.numChoices = r.NumChoices
+	g.tier = r.Tier
+	gRules = r.Rules
+	g.improv = setupImprovements()
+	g.improv.levels = r.Improvements
+	g.money.money = r.Money
+	g.money.displayMoney = r.Money
+	g.replaying = &r
+	g.replayFrame = 0
+	g.firstPlay = false
+	g.startRun(r.Seed)
+}
@@ -0,0 +1,106 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/loig/ebitenginegamejam2024/assets"
+)
+
+// Between levels, the maluses offered can be drawn again once the
+// reroll improvement is bought. A reroll is paid with coins, or with
+// a heart when there are not enough coins. With the skip improvement
+// a skip token is sometimes offered along with the maluses, taking
+// it (for a few coins) gives the next level without a new malus.
+
+// coins needed for a reroll, -1 when rerolls are not available
+func (g game) rerollCost() int {
+	level := g.improv.levels[improveReroll]
+	if level == 0 {
+		return -1
+	}
+	return ruleAt(gRules.RerollCosts, level-1)
+}
+
+// draw a new set of maluses if it can be paid, coins are used first
+func (g *game) reroll() (done bool) {
+	cost := g.rerollCost()
+	switch {
+	case cost < 0:
+		return false
+	case g.money.money >= cost:
+		g.money.money -= cost
+		g.money.displayMoney = g.money.money
+	case g.currentPlay.currentLife > 0:
+		g.currentPlay.lostLives++
+		g.currentPlay.currentLife--
+	default:
+		return false
+	}
+	g.balance.choice = 0
+	g.offerMaluses()
+	return true
+}
+
+// pay for the skip token
+func (g *game) paySkip() (done bool) {
+	if g.money.money < g.balance.skipCost() {
+		return false
+	}
+	g.money.money -= g.balance.skipCost()
+	g.money.displayMoney = g.money.money
+	return true
+}
+
+// show how to reroll and its cost, on the left of the balancing wheel
+func (g game) drawReroll(screen *ebiten.Image) {
+
+	cost := g.rerollCost()
+	if cost < 0 {
+		return
+	}
+
+	scaling := 0.6
+	size := scaling * float64(gImproveTextWidth)
+	x, y := gWidth/6, gHeight/2-30
+
+	var gray uint8 = 255
+	if g.money.money < cost && g.currentPlay.currentLife <= 0 {
+		gray = 100
+	}
+
+	drawArrow(screen, x-gArrowWidth/2, y-int(size/2)-gArrowHeight, 0, 0)
+
+	options := ebiten.DrawImageOptions{}
+	options.ColorScale.ScaleWithColor(color.Gray{gray})
+	options.GeoM.Scale(scaling, scaling)
+	options.GeoM.Translate(float64(x)-size/2, float64(y)-size/2)
+	screen.DrawImage(improvementIcon(improveReroll), &options)
+
+	if g.money.money >= cost || g.currentPlay.currentLife <= 0 {
+		drawMoney(screen, x, y+int(size/2)+20, cost, false, 0.4)
+		return
+	}
+
+	options = ebiten.DrawImageOptions{}
+	options.GeoM.Translate(float64(x-gHeartWidth/2), float64(y)+size/2)
+	screen.DrawImage(assets.ImageFullHeart, &options)
+}
@@ -0,0 +1,84 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/loig/ebitenginegamejam2024/assets"
+)
+
+// push lines of garbage with one random hole
+// in each of them at the bottom of the grid
+func (t *tetris) pushGarbage(lines int) {
+	copy(t.area[:], t.area[lines:])
+	for y := len(t.area) - lines; y < len(t.area); y++ {
+		hole := t.randomHole()
+		for x := range t.area[y] {
+			t.area[y][x] = garbageStyle
+			if x == hole {
+				t.area[y][x] = noStyle
+			}
+			if t.isWall(x) {
+				t.area[y][x] = wallStyle
+			}
+		}
+	}
+}
+
+// count a locked piece and push a line of garbage when enough of them
+// have been locked, this must be done before checking the danger zone
+func (t *tetris) updateRise() {
+	if t.risePieces <= 0 {
+		return
+	}
+	t.riseCount++
+	if t.riseCount >= t.risePieces {
+		t.riseCount = 0
+		t.pushGarbage(1)
+	}
+}
+
+// number of pieces left before the next line of garbage
+func (t tetris) riseLeft() int {
+	return t.risePieces - t.riseCount
+}
+
+// garbage square with the number of pieces left below it,
+// in the left border of the play area
+func (t tetris) drawRise(screen *ebiten.Image, gray uint8) {
+	if t.risePieces <= 0 {
+		return
+	}
+
+	scaling := 0.5
+	size := int(float64(gSquareSideSize) * scaling)
+	x := (gPlayAreaSide - size) / 2
+	y := gPlayAreaHeight - 2*size - gPlayAreaSide/2
+
+	options := ebiten.DrawImageOptions{}
+	options.ColorScale.ScaleWithColor(color.Gray{gray})
+	options.GeoM.Scale(scaling, scaling)
+	options.GeoM.Translate(float64(x), float64(y))
+	screen.DrawImage(assets.ImageSquares.SubImage(image.Rect((garbageStyle-1)*gSquareSideSize, 0, garbageStyle*gSquareSideSize, gSquareSideSize)).(*ebiten.Image), &options)
+
+	drawScaledNumberAt(screen, gray, gPlayAreaSide-(gPlayAreaSide-2*size)/2, y+size, t.riseLeft(), -1, scaling)
+}
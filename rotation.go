@@ -0,0 +1,91 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// levels of the rotation restriction malus,
+// each level keeps the restrictions of the previous ones
+const (
+	rotationOneWay    int = 1 // rotating left is not possible
+	rotationCooldown  int = 2 // rotations need time to recover
+	rotationTwoStates int = 3 // pieces only have two rotation states
+)
+
+const (
+	rotationCooldownFrames int     = 30 // frames between two rotations
+	rotationBarWidth       float32 = 16 // width of the cooldown bar in pixels
+	rotationBarHeight      float32 = 160
+)
+
+var rotationReadyColor = color.RGBA{224, 248, 208, 255}
+var rotationBarColor = color.RGBA{8, 24, 32, 255}
+
+// number of rotation states that pieces can use
+func (t tetris) rotationStates() int {
+	if t.rotationLevel >= rotationTwoStates {
+		return 2
+	}
+	return 4
+}
+
+// rotate the active piece if the rotation restriction allows it,
+// refused rotations are not effective so they do not reset the
+// automatic down movement when the player has the improvement
+func (t *tetris) rotate(left, right bool) (effective bool) {
+
+	if t.rotationCooldown > 0 {
+		t.rotationCooldown--
+		return false
+	}
+
+	if left && !right && t.rotationLevel < rotationOneWay {
+		effective = t.currentBlock.rotateLeft(t.area, t.rotationStates())
+	}
+
+	if right && !left {
+		effective = t.currentBlock.rotateRight(t.area, t.rotationStates())
+	}
+
+	if effective && t.rotationLevel >= rotationCooldown {
+		t.rotationCooldown = rotationCooldownFrames
+	}
+
+	return
+}
+
+// bar in the left border of the play area, full when rotating is possible
+func (t tetris) drawRotation(screen *ebiten.Image, gray uint8) {
+	if t.rotationLevel < rotationCooldown {
+		return
+	}
+
+	x := (float32(gPlayAreaSide) - rotationBarWidth) / 2
+	y := float32(gPlayAreaHeight)/2 - rotationBarHeight/2
+
+	ready := rotationBarHeight * float32(rotationCooldownFrames-t.rotationCooldown) / float32(rotationCooldownFrames)
+
+	vector.DrawFilledRect(screen, x, y, rotationBarWidth, rotationBarHeight, grayColor(rotationBarColor, gray), false)
+	vector.DrawFilledRect(screen, x, y+rotationBarHeight-ready, rotationBarWidth, ready, grayColor(rotationReadyColor, gray), false)
+}
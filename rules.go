@@ -0,0 +1,356 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Game rules that can be tuned without rebuilding the game. The
+// defaults are the values below, a rules file (JSON) only needs to
+// give the values that should be changed, for example
+//
+//	{"goalLevel": 8, "deathLines": [1, 2, 4, 6, 8, 10]}
+type rules struct {
+	Speeds         []int       `json:"speeds"`         // frames for falling one row, for each speed level
+	GoalLevel      int         `json:"goalLevel"`      // level to reach for winning
+	NumChoices     int         `json:"numChoices"`     // number of maluses offered between levels
+	ScoreToMoney   int         `json:"scoreToMoney"`   // score needed for earning one coin
+	Prices         rulesPrices `json:"prices"`         // prices of the successive levels of improvements
+	GoalLines      []int       `json:"goalLines"`      // lines to complete a level, for each goal lines malus level
+	SpeedLevels    []int       `json:"speedLevels"`    // speed levels added, for each speed malus level
+	DeathLines     []int       `json:"deathLines"`     // size of the danger zone, for each death lines malus level
+	HiddenLines    []int       `json:"hiddenLines"`    // lines hidden by fog, for each hidden lines malus level
+	RisePieces     []int       `json:"risePieces"`     // pieces between two garbage rows (0 for none), for each rising floor malus level
+	TimeLimits     []int       `json:"timeLimits"`     // seconds for completing a level (0 for no limit), for each time limit malus level
+	MirrorFrames   []int       `json:"mirrorFrames"`   // frames with normal controls between two flips, for each mirror malus level from the first one
+	MirroredFrames []int       `json:"mirroredFrames"` // frames with mirrored controls before flipping back, for each mirror malus level from the first one
+	BiasWeights    [][]int     `json:"biasWeights"`    // weights of the pieces (I, O, J, L, S, T, Z), for each bad luck malus level from the first one
+	BiasDroughts   []int       `json:"biasDroughts"`   // pieces generated after an I piece before another one can come, for each bad luck malus level from the first one
+	WindPeriods    []int       `json:"windPeriods"`    // frames between two gusts, for each wind malus level from the first one
+	WindStrengths  []int       `json:"windStrengths"`  // columns pushed by a gust, for each wind malus level from the first one
+	DarknessRadius []int       `json:"darknessRadius"` // tenths of squares lit around the falling piece, for each darkness malus level from the first one
+	RerollCosts    []int       `json:"rerollCosts"`    // coins for drawing new maluses, for each reroll improvement level from the first one
+	SkipChances    []int       `json:"skipChances"`    // percent chance of offering a skip token, for each skip improvement level from the first one
+	SkipCost       int         `json:"skipCost"`       // coins for using a skip token
+	Offers         rulesOffers `json:"offers"`         // weighting of the maluses offered between levels
+	BonusChance    int         `json:"bonusChance"`    // percent chance for a malus offered to come with a bonus
+	BonusMoney     int         `json:"bonusMoney"`     // percentage of the score added at the end of the run by a money bonus
+	CleanseChance  int         `json:"cleanseChance"`  // percent chance of offering to cleanse a malus, without cleanse charges
+	Tiers          []rulesTier `json:"tiers"`          // harder runs unlocked one by one by winning, from tier 1
+	SlowStack      int         `json:"slowStack"`      // charges of slow time in a stack bought in the shop
+	SlowSeconds    int         `json:"slowSeconds"`    // seconds at half speed for each charge of slow time
+}
+
+type rulesPrices struct {
+	Life          []int `json:"life"`
+	Hold          []int `json:"hold"`
+	ResetAutoDown []int `json:"resetAutoDown"`
+	HideMove      []int `json:"hideMove"`
+	Reroll        []int `json:"reroll"`
+	Skip          []int `json:"skip"`
+	Cleanse       []int `json:"cleanse"`
+	Ghost         []int `json:"ghost"`
+	Slow          []int `json:"slow"`
+}
+
+// The weight of a malus in an offer is its base weight (100 by default)
+// times the percentage given by its level curve, for example
+//
+//	{"offers": {"weights": {"speed": 150}, "levelCurves": {"time": [0, 50, 100]}}}
+//
+// makes the speed malus more frequent and the time limit malus
+// never offered after the first level, and less often after the second.
+type rulesOffers struct {
+	Weights     map[string]int   `json:"weights"`     // base weight of maluses, by ID
+	LevelCurves map[string][]int `json:"levelCurves"` // percentage of the weight of maluses by ID, for each offer of the run
+	Repeat      int              `json:"repeat"`      // percentage of the weight of the maluses offered the previous time
+	Pity        int              `json:"pity"`        // offers without a malus before its weight grows (0 for never)
+	PityGrowth  int              `json:"pityGrowth"`  // percentage of the weight added for each further offer without it
+	NearMaxed   int              `json:"nearMaxed"`   // levels below the max level for a malus to be near-maxed
+}
+
+// A tier makes all the runs started with it harder, for example
+//
+//	{"tiers": [{"goalLines": 2}, {"maluses": {"speed": 2}, "hearts": 1}]}
+//
+// gives two tiers, the second one starting with the speed malus at level 2.
+type rulesTier struct {
+	Maluses   map[string]int `json:"maluses"`   // starting level of maluses, by ID
+	Hearts    int            `json:"hearts"`    // hearts removed from the ones given by the improvements, or danger lines added when there are none left
+	GoalLines int            `json:"goalLines"` // lines added to the goal of each level
+	GoalLevel int            `json:"goalLevel"` // levels added to the level to reach for winning
+}
+
+// largest danger zone, in lines, keeping room for playing
+const maxDeathLines int = 2*gPlayAreaHeightInBlocks/3 - 1
+
+// rules in use
+var gRules rules = defaultRules()
+
+func defaultRules() rules {
+	return rules{
+		Speeds: []int{
+			53, 49, 45, 41, 37, 33, 28, 22, 17, 11, 10,
+			9, 8, 7, 6, 6, 5, 5, 4, 4, 3,
+		},
+		GoalLevel:    11,
+		NumChoices:   3,
+		ScoreToMoney: 100,
+		Prices: rulesPrices{
+			Life:          []int{10, 50, 150},
+			Hold:          []int{150},
+			ResetAutoDown: []int{300},
+			HideMove:      []int{20, 75, 250},
+			Reroll:        []int{60, 180, 400},
+			Skip:          []int{200, 450, 900},
+			Cleanse:       []int{40, 80, 120},
+			Ghost:         []int{50, 150, 300},
+			Slow:          []int{30, 60, 100},
+		},
+		GoalLines:      []int{4, 8, 12},
+		SpeedLevels:    []int{1, 2, 4, 7, 10},
+		DeathLines:     []int{1, 3, 5, 7, 9, 11},
+		HiddenLines:    []int{0, 3, 6, 9, 12, 15},
+		RisePieces:     []int{0, 15, 11, 8, 5},
+		TimeLimits:     []int{0, 150, 110, 75},
+		MirrorFrames:   []int{2400, 1500, 900},
+		MirroredFrames: []int{480, 600, 720},
+		BiasWeights: [][]int{
+			{2, 2, 2, 2, 3, 2, 3},
+			{1, 2, 2, 2, 4, 2, 4},
+			{1, 2, 2, 2, 6, 2, 6},
+		},
+		BiasDroughts:   []int{0, 6, 10},
+		WindPeriods:    []int{420, 300, 180},
+		WindStrengths:  []int{1, 1, 2},
+		DarknessRadius: []int{45, 35, 25},
+		RerollCosts:    []int{20, 12, 5},
+		SkipChances:    []int{10, 20, 35},
+		SkipCost:       15,
+		Offers: rulesOffers{
+			LevelCurves: map[string][]int{
+				"mirror": {0, 50, 100},
+				"dark":   {0, 50, 100},
+			},
+			Repeat:     50,
+			Pity:       3,
+			PityGrowth: 50,
+			NearMaxed:  1,
+		},
+		BonusChance:   25,
+		BonusMoney:    50,
+		CleanseChance: 5,
+		SlowStack:     3,
+		SlowSeconds:   5,
+		Tiers: []rulesTier{
+			{GoalLines: 2},
+			{Maluses: map[string]int{"speed": 1}, GoalLines: 2},
+			{Maluses: map[string]int{"speed": 1, "hidden": 1}, Hearts: 1, GoalLines: 2},
+			{Maluses: map[string]int{"speed": 2, "hidden": 1, "death": 1}, Hearts: 1, GoalLines: 4, GoalLevel: 2},
+			{Maluses: map[string]int{"speed": 2, "hidden": 2, "death": 1, "bias": 1}, Hearts: 2, GoalLines: 4, GoalLevel: 4},
+		},
+	}
+}
+
+// read rules from a file, starting from the default ones
+func loadRules(path string) (r rules, err error) {
+	r = defaultRules()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return r, fmt.Errorf("rules: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(&r); err != nil {
+		return r, fmt.Errorf("rules %s: %w", path, err)
+	}
+
+	if err = r.validate(); err != nil {
+		return r, fmt.Errorf("rules %s:\n%w", path, err)
+	}
+
+	return r, nil
+}
+
+func (r rules) validate() error {
+
+	var errs []error
+
+	checkTable := func(name string, table []int, min, max int) {
+		if len(table) == 0 {
+			errs = append(errs, fmt.Errorf("%s: at least one value is needed", name))
+		}
+		for i, v := range table {
+			if v < min || v > max {
+				errs = append(errs, fmt.Errorf("%s: value %d (at position %d) should be between %d and %d", name, v, i, min, max))
+			}
+		}
+	}
+
+	checkTable("speeds", r.Speeds, 1, 1000)
+	checkTable("goalLines", r.GoalLines, 1, 1000)
+	if len(r.Speeds) > 0 {
+		checkTable("speedLevels", r.SpeedLevels, 0, len(r.Speeds)-1)
+	}
+	checkTable("deathLines", r.DeathLines, 0, maxDeathLines)
+	checkTable("hiddenLines", r.HiddenLines, 0, gPlayAreaHeightInBlocks)
+	checkTable("risePieces", r.RisePieces, 0, 1000)
+	checkTable("timeLimits", r.TimeLimits, 0, 3600)
+	checkTable("mirrorFrames", r.MirrorFrames, mirrorWarningFrames+1, 36000)
+	checkTable("mirroredFrames", r.MirroredFrames, 1, 36000)
+	if len(r.BiasWeights) == 0 {
+		errs = append(errs, errors.New("biasWeights: at least one value is needed"))
+	}
+	for i, weights := range r.BiasWeights {
+		name := fmt.Sprintf("biasWeights[%d]", i)
+		if len(weights) != 7 {
+			errs = append(errs, fmt.Errorf("%s: should give 7 weights, got %d", name, len(weights)))
+			continue
+		}
+		checkTable(name, weights, 0, 1000)
+		// I pieces are removed from the weights during a drought
+		others := 0
+		for _, w := range weights[1:] {
+			others += w
+		}
+		if others <= 0 {
+			errs = append(errs, fmt.Errorf("%s: a piece other than I should have a weight", name))
+		}
+	}
+	checkTable("biasDroughts", r.BiasDroughts, 0, 100)
+	checkTable("windPeriods", r.WindPeriods, windUpFrames+1, 36000)
+	checkTable("windStrengths", r.WindStrengths, 1, gPlayAreaWidthInBlocks-1)
+	checkTable("darknessRadius", r.DarknessRadius, 5, 10*gPlayAreaHeightInBlocks)
+	checkTable("rerollCosts", r.RerollCosts, 0, 1000000)
+	checkTable("skipChances", r.SkipChances, 0, 100)
+	checkTable("prices.life", r.Prices.Life, 0, 1000000)
+	checkTable("prices.hold", r.Prices.Hold, 0, 1000000)
+	checkTable("prices.resetAutoDown", r.Prices.ResetAutoDown, 0, 1000000)
+	checkTable("prices.hideMove", r.Prices.HideMove, 0, 1000000)
+	checkTable("prices.reroll", r.Prices.Reroll, 0, 1000000)
+	checkTable("prices.skip", r.Prices.Skip, 0, 1000000)
+	checkTable("prices.cleanse", r.Prices.Cleanse, 0, 1000000)
+	checkTable("prices.ghost", r.Prices.Ghost, 0, 1000000)
+	checkTable("prices.slow", r.Prices.Slow, 0, 1000000)
+
+	if r.GoalLevel < 1 {
+		errs = append(errs, fmt.Errorf("goalLevel: should be at least 1, got %d", r.GoalLevel))
+	}
+	if r.NumChoices < 1 || r.NumChoices > len(gMaluses) {
+		errs = append(errs, fmt.Errorf("numChoices: should be between 1 and %d, got %d", len(gMaluses), r.NumChoices))
+	}
+	for id, w := range r.Offers.Weights {
+		if _, found := findMalus(id); !found {
+			errs = append(errs, fmt.Errorf("offers.weights: unknown malus %q", id))
+		}
+		if w < 0 || w > 10000 {
+			errs = append(errs, fmt.Errorf("offers.weights.%s: should be between 0 and 10000, got %d", id, w))
+		}
+	}
+	for id, curve := range r.Offers.LevelCurves {
+		if _, found := findMalus(id); !found {
+			errs = append(errs, fmt.Errorf("offers.levelCurves: unknown malus %q", id))
+		}
+		checkTable("offers.levelCurves."+id, curve, 0, 1000)
+	}
+	if r.Offers.Repeat < 0 || r.Offers.Repeat > 1000 {
+		errs = append(errs, fmt.Errorf("offers.repeat: should be between 0 and 1000, got %d", r.Offers.Repeat))
+	}
+	if r.Offers.Pity < 0 {
+		errs = append(errs, fmt.Errorf("offers.pity: should be at least 0, got %d", r.Offers.Pity))
+	}
+	if r.Offers.PityGrowth < 0 || r.Offers.PityGrowth > 1000 {
+		errs = append(errs, fmt.Errorf("offers.pityGrowth: should be between 0 and 1000, got %d", r.Offers.PityGrowth))
+	}
+	if r.Offers.NearMaxed < 0 {
+		errs = append(errs, fmt.Errorf("offers.nearMaxed: should be at least 0, got %d", r.Offers.NearMaxed))
+	}
+	if r.BonusChance < 0 || r.BonusChance > 100 {
+		errs = append(errs, fmt.Errorf("bonusChance: should be between 0 and 100, got %d", r.BonusChance))
+	}
+	if r.CleanseChance < 0 || r.CleanseChance > 100 {
+		errs = append(errs, fmt.Errorf("cleanseChance: should be between 0 and 100, got %d", r.CleanseChance))
+	}
+	if r.BonusMoney < 0 || r.BonusMoney > 1000 {
+		errs = append(errs, fmt.Errorf("bonusMoney: should be between 0 and 1000, got %d", r.BonusMoney))
+	}
+	if r.SkipCost < 0 {
+		errs = append(errs, fmt.Errorf("skipCost: should be at least 0, got %d", r.SkipCost))
+	}
+	for i, tier := range r.Tiers {
+		name := fmt.Sprintf("tiers[%d]", i)
+		for id, level := range tier.Maluses {
+			pos, found := findMalus(id)
+			if !found {
+				errs = append(errs, fmt.Errorf("%s.maluses: unknown malus %q", name, id))
+				continue
+			}
+			if level < 0 || level > gMaluses[pos].MaxLevel() {
+				errs = append(errs, fmt.Errorf("%s.maluses.%s: should be between 0 and %d, got %d", name, id, gMaluses[pos].MaxLevel(), level))
+			}
+		}
+		if tier.Hearts < 0 {
+			errs = append(errs, fmt.Errorf("%s.hearts: should be at least 0, got %d", name, tier.Hearts))
+		}
+		if tier.GoalLines < 0 || tier.GoalLines > 1000 {
+			errs = append(errs, fmt.Errorf("%s.goalLines: should be between 0 and 1000, got %d", name, tier.GoalLines))
+		}
+		if tier.GoalLevel < 0 {
+			errs = append(errs, fmt.Errorf("%s.goalLevel: should be at least 0, got %d", name, tier.GoalLevel))
+		}
+	}
+	if r.SlowStack < 1 || r.SlowStack > 100 {
+		errs = append(errs, fmt.Errorf("slowStack: should be between 1 and 100, got %d", r.SlowStack))
+	}
+	if r.SlowSeconds < 1 || r.SlowSeconds > 60 {
+		errs = append(errs, fmt.Errorf("slowSeconds: should be between 1 and 60, got %d", r.SlowSeconds))
+	}
+	if r.ScoreToMoney < 1 {
+		errs = append(errs, fmt.Errorf("scoreToMoney: should be at least 1, got %d", r.ScoreToMoney))
+	}
+
+	return errors.Join(errs...)
+}
+
+// prices of the improvements, indexed as in improvements
+func (r rules) improvementPrices() (prices [numImprove][]int) {
+	for id, imp := range gImprovements {
+		prices[id] = imp.prices(r.Prices)
+	}
+	return
+}
+
+// get the value of a table for a given level, the last
+// value is used for levels that are beyond the table
+func ruleAt(table []int, level int) int {
+	if level >= len(table) {
+		level = len(table) - 1
+	}
+	if level < 0 {
+		level = 0
+	}
+	return table[level]
+}
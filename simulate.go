@@ -0,0 +1,373 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Headless simulator for tuning the balance of the game: complete runs
+// are played by the bot, without window nor audio, for each combination
+// of starting malus levels and improvements given on the command line.
+//
+//	yatc simulate -runs 500 -malus speed=0:5 -malus death=0,2 -improve life=0:3 -o speed.csv
+//
+// During the runs, the bot always takes the first malus it is offered.
+// It sees the whole grid and knows when the controls are mirrored, so
+// fog, invisible blocks and mirrored controls do not change its results,
+// only their effects on the rules do.
+
+const simFramesPerSecond = 60
+
+// values taken by one malus or improvement level in a sweep
+type simSweep struct {
+	name   string
+	values []int
+}
+
+type simSweeps []simSweep
+
+func (s *simSweeps) String() string {
+	parts := make([]string, len(*s))
+	for i, sweep := range *s {
+		parts[i] = fmt.Sprint(sweep.name, "=", sweep.values)
+	}
+	return strings.Join(parts, " ")
+}
+
+// read name=values where values is either a range (1:4) or a list (0,2,5)
+func (s *simSweeps) Set(arg string) error {
+	name, spec, found := strings.Cut(arg, "=")
+	if !found {
+		return fmt.Errorf("%q should be name=values", arg)
+	}
+	sweep := simSweep{name: name}
+	if from, to, isRange := strings.Cut(spec, ":"); isRange {
+		min, err1 := strconv.Atoi(from)
+		max, err2 := strconv.Atoi(to)
+		if err1 != nil || err2 != nil || min > max {
+			return fmt.Errorf("%q is not a valid range", spec)
+		}
+		for v := min; v <= max; v++ {
+			sweep.values = append(sweep.values, v)
+		}
+	} else {
+		for _, v := range strings.Split(spec, ",") {
+			value, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%q is not a number", v)
+			}
+			sweep.values = append(sweep.values, value)
+		}
+	}
+	*s = append(*s, sweep)
+	return nil
+}
+
+// starting setup of simulated runs
+type simConfig struct {
+	levels       []int
+	improvements [numImprove]int
+}
+
+// statistics over the runs of one configuration
+type simResult struct {
+	Maluses      map[string]int `json:"maluses"`
+	Improvements map[string]int `json:"improvements"`
+	Runs         int            `json:"runs"`
+	WinRate      float64        `json:"winRate"`
+	Lines        float64        `json:"lines"`   // mean number of lines cleared
+	Seconds      float64        `json:"seconds"` // mean survival time
+	Score        float64        `json:"score"`
+	Money        float64        `json:"money"`
+	Level        float64        `json:"level"` // mean level reached
+}
+
+// entry point of the simulate command, returns the exit code
+func runSimulate(args []string, stdout, stderr io.Writer) int {
+
+	var maluses, improves simSweeps
+	var runs, numChoices, goalLevel, maxMinutes int
+	var seed int64
+	var format, outPath, rulesPath string
+
+	fs := flag.NewFlagSet("yatc simulate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: yatc simulate [options]")
+		fs.PrintDefaults()
+		fmt.Fprintln(stderr, "maluses:", strings.Join(malusIDs(), ", "))
+		fmt.Fprintln(stderr, "improvements:", strings.Join(improvementIDs(), ", "))
+	}
+	fs.Var(&maluses, "malus", "starting malus levels to try, as name=min:max or name=v1,v2,... (repeatable)")
+	fs.Var(&improves, "improve", "improvement levels to try, as name=min:max or name=v1,v2,... (repeatable)")
+	fs.IntVar(&runs, "runs", 100, "number of runs for each configuration")
+	fs.Int64Var(&seed, "seed", 1, "seed of the first run, the following ones use the next seeds")
+	fs.IntVar(&numChoices, "choices", 0, "number of maluses offered between levels (0 for the value of the rules)")
+	fs.IntVar(&goalLevel, "goal", 0, "level to reach for winning (0 for the value of the rules)")
+	fs.IntVar(&maxMinutes, "max-minutes", 60, "stop runs lasting longer than this, in game time")
+	fs.StringVar(&format, "format", "csv", "output format: csv or json")
+	fs.StringVar(&outPath, "o", "", "output file (standard output by default)")
+	fs.StringVar(&rulesPath, "rules", "", "JSON file for tuning the rules of the game")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	usageError := func(err error) int {
+		fmt.Fprintln(stderr, err)
+		fs.Usage()
+		return 2
+	}
+
+	if rulesPath != "" {
+		var err error
+		if gRules, err = loadRules(rulesPath); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+	if numChoices == 0 {
+		numChoices = gRules.NumChoices
+	}
+	if goalLevel == 0 {
+		goalLevel = gRules.GoalLevel
+	}
+
+	if fs.NArg() > 0 {
+		return usageError(fmt.Errorf("unexpected argument %q", fs.Arg(0)))
+	}
+	if runs < 1 {
+		return usageError(fmt.Errorf("runs: must be at least 1, got %d", runs))
+	}
+	if numChoices < 1 || numChoices > len(gMaluses) {
+		return usageError(fmt.Errorf("choices: must be between 1 and %d, got %d", len(gMaluses), numChoices))
+	}
+	if goalLevel < 1 {
+		return usageError(fmt.Errorf("goal: must be at least 1, got %d", goalLevel))
+	}
+	if format != "csv" && format != "json" {
+		return usageError(fmt.Errorf("format: %q is not csv or json", format))
+	}
+
+	configs, err := simConfigs(maluses, improves)
+	if err != nil {
+		return usageError(err)
+	}
+
+	out := stdout
+	if outPath != "" {
+		file, err := os.Create(outPath)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		defer file.Close()
+		out = file
+	}
+
+	results := make([]simResult, 0, len(configs))
+	for num, config := range configs {
+		fmt.Fprintf(stderr, "configuration %d/%d\n", num+1, len(configs))
+		results = append(results, simulateConfig(config, runs, seed, numChoices, goalLevel, maxMinutes*60*simFramesPerSecond))
+	}
+
+	if format == "json" {
+		err = writeSimJSON(out, results)
+	} else {
+		err = writeSimCSV(out, results)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+// all the combinations of the sweeps
+func simConfigs(maluses, improves simSweeps) ([]simConfig, error) {
+
+	configs := []simConfig{{levels: make([]int, len(gMaluses))}}
+
+	for _, sweep := range maluses {
+		id, found := findMalus(sweep.name)
+		if !found {
+			return nil, fmt.Errorf("malus: unknown malus %q", sweep.name)
+		}
+		next := make([]simConfig, 0, len(configs)*len(sweep.values))
+		for _, config := range configs {
+			for _, v := range sweep.values {
+				if v < 0 || v > gMaluses[id].MaxLevel() {
+					return nil, fmt.Errorf("malus: level %d of %s is not between 0 and %d", v, sweep.name, gMaluses[id].MaxLevel())
+				}
+				levels := append([]int{}, config.levels...)
+				levels[id] = v
+				next = append(next, simConfig{levels: levels, improvements: config.improvements})
+			}
+		}
+		configs = next
+	}
+
+	prices := gRules.improvementPrices()
+	for _, sweep := range improves {
+		id, found := findImprovement(sweep.name)
+		if !found {
+			return nil, fmt.Errorf("improve: unknown improvement %q", sweep.name)
+		}
+		next := make([]simConfig, 0, len(configs)*len(sweep.values))
+		for _, config := range configs {
+			for _, v := range sweep.values {
+				if v < 0 || v > len(prices[id]) {
+					return nil, fmt.Errorf("improve: level %d of %s is not between 0 and %d", v, sweep.name, len(prices[id]))
+				}
+				config.improvements[id] = v
+				next = append(next, config)
+			}
+		}
+		configs = next
+	}
+
+	return configs, nil
+}
+
+// play runs for one configuration and gather the results
+func simulateConfig(config simConfig, runs int, seed int64, numChoices, goalLevel, maxFrames int) (result simResult) {
+
+	result.Maluses = make(map[string]int)
+	for id, m := range gMaluses {
+		result.Maluses[m.ID()] = config.levels[id]
+	}
+	result.Improvements = make(map[string]int)
+	for id, imp := range gImprovements {
+		result.Improvements[imp.id] = config.improvements[id]
+	}
+	result.Runs = runs
+
+	for run := 0; run < runs; run++ {
+		lines, frames, score, money, level, won := simulateRun(config, seed+int64(run), numChoices, goalLevel, maxFrames)
+		if won {
+			result.WinRate++
+		}
+		result.Lines += float64(lines)
+		result.Seconds += float64(frames) / simFramesPerSecond
+		result.Score += float64(score)
+		result.Money += float64(money)
+		result.Level += float64(level)
+	}
+
+	result.WinRate /= float64(runs)
+	result.Lines /= float64(runs)
+	result.Seconds /= float64(runs)
+	result.Score /= float64(runs)
+	result.Money /= float64(runs)
+	result.Level /= float64(runs)
+
+	return
+}
+
+// play one complete run with the bot, using the same state
+// handling as the game, but without reading the keyboard
+func simulateRun(config simConfig, seed int64, numChoices, goalLevel, maxFrames int) (lines, frames, score, money, level int, won bool) {
+
+	g := game{
+		mode:       modeStandard,
+		numChoices: numChoices,
+		goalLevel:  goalLevel,
+		improv:     setupImprovements(),
+	}
+	g.improv.levels = config.improvements
+	g.startRun(seed)
+	copy(g.balance.levels, config.levels)
+	g.startLevel(0, g.maxLife())
+
+	b := bot{piece: -1}
+
+	for ; frames < maxFrames; frames++ {
+		previousLevel, previousLines := g.level, g.currentPlay.numLines
+
+		g.input = b.play(g)
+		g.updateState()
+
+		if g.level != previousLevel {
+			lines += previousLines
+		}
+		if g.state == stateLost || g.state == stateWon {
+			break
+		}
+	}
+
+	lines += g.currentPlay.numLines
+	score = g.currentPlay.score
+	money = g.money.money // paid when the run is lost
+	if g.state != stateLost {
+		money = (score + score*g.money.bonus/100) / gRules.ScoreToMoney
+	}
+	return lines, frames, score, money, g.level + 1, g.state == stateWon
+}
+
+func writeSimJSON(out io.Writer, results []simResult) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(results)
+}
+
+func writeSimCSV(out io.Writer, results []simResult) error {
+	w := csv.NewWriter(out)
+
+	header := malusIDs()
+	header = append(header, improvementIDs()...)
+	header = append(header, "runs", "win_rate", "lines", "seconds", "score", "money", "level")
+	if err := w.Write(header); err != nil {
+		return err
+	}
+
+	format := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', 3, 64)
+	}
+
+	for _, r := range results {
+		record := make([]string, 0, len(header))
+		for _, name := range malusIDs() {
+			record = append(record, strconv.Itoa(r.Maluses[name]))
+		}
+		for _, name := range improvementIDs() {
+			record = append(record, strconv.Itoa(r.Improvements[name]))
+		}
+		record = append(record, strconv.Itoa(r.Runs), format(r.WinRate), format(r.Lines),
+			format(r.Seconds), format(r.Score), format(r.Money), format(r.Level))
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
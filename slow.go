@@ -0,0 +1,82 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/loig/ebitenginegamejam2024/assets"
+)
+
+// Stacks of slow time charges are bought in the shop. They become
+// charges of the run at the start of the next level, and the charges
+// left are kept from one level to the next until the end of the run.
+// Using a charge makes gravity, fog and invisible blocks go at half
+// speed for a few seconds.
+
+const (
+	slowIconSize   int = 48
+	slowBlinkFrame int = 15
+
+	// position of the charges, right of the timer and above the next piece
+	slowXFromLeftSide int = gPlayAreaSide + gPlayAreaWidth + gPlayAreaSide + gInfoLeftSide + 3*gInfoWidth/5
+	slowYFromTop      int = timeYFromTop - 8
+)
+
+// turn the stacks bought into charges of the run
+func (g *game) takeSlowStacks(stacks int) {
+	g.slowCharges += stacks * gRules.SlowStack
+	g.improv.levels[improveSlow] = 0
+}
+
+// use a charge, if any and if the game is not already slowed
+func (g *game) useSlow() (done bool) {
+	t := &g.currentPlay
+	if g.slowCharges <= 0 || t.slowFrames > 0 || t.dead {
+		return false
+	}
+	g.slowCharges--
+	t.slowFrames = gRules.SlowSeconds * timeFramesPerSecond
+	return true
+}
+
+// check if the slowed parts of the game skip the current frame
+func (t tetris) slowSkip() bool {
+	return t.slowFrames%2 == 1
+}
+
+// charges left, the icon blinks while the game is slowed
+func (g game) drawSlow(screen *ebiten.Image, gray uint8) {
+
+	if g.slowCharges <= 0 && g.currentPlay.slowFrames <= 0 {
+		return
+	}
+
+	if g.currentPlay.slowFrames <= 0 || (g.currentPlay.slowFrames/slowBlinkFrame)%2 == 0 {
+		options := ebiten.DrawImageOptions{}
+		options.ColorScale.ScaleWithColor(color.Gray{gray})
+		scaling := float64(slowIconSize) / float64(assets.ImageSlow.Bounds().Dx())
+		options.GeoM.Scale(scaling, scaling)
+		options.GeoM.Translate(float64(slowXFromLeftSide), float64(slowYFromTop))
+		screen.DrawImage(assets.ImageSlow, &options)
+	}
+
+	drawScaledNumberAt(screen, gray, slowXFromLeftSide+slowIconSize+gSquareSideSize+8, slowYFromTop+(slowIconSize-gSquareSideSize/2)/2, g.slowCharges, -1, 0.5)
+}
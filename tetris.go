@@ -19,9 +19,9 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 package main
 
 import (
+	"fmt"
 	"image"
 	"image/color"
-	"math/rand"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/loig/ebitenginegamejam2024/assets"
@@ -47,11 +47,17 @@ type tetris struct {
 	manualMoveAllowed     bool
 	numLines              int
 	dropLenght            int
-	deathLines            int
+	speedLevel            int
+	numPieces             int // pieces played since the start of the run
+	// effects of maluses, set by their Apply
+	deathLines  int
+	goalLines   int
+	hiddenLines int
 	// animation and lines removal handling
 	toCheck                          [2]int
 	toRemove                         [4]bool
 	toRemoveNum                      int
+	tetrises                         int // lines removed four at once during the level
 	firstAvailable                   int
 	removeLineAnimationFrame         int
 	removeLineAnimationStep          int
@@ -61,27 +67,70 @@ type tetris struct {
 	invisibleLevel int
 	invisibleStep  int
 	invisibleFrame int
+	// mirrored controls handling
+	mirrorLevel int
+	mirrorFrame int
+	mirrored    bool
+	// rising floor handling
+	risePieces int
+	riseCount  int
+	// preview corruption handling
+	previewLevel int
+	previewFrame int
+	previewOff   bool
+	previewBlock tetrisBlock // wrong piece shown instead of the next one, if any
+	// bad luck handling
+	biasLevel    int
+	piecesSinceI int
+	pieceCounts  [numStyles]int // pieces generated since the start of the run
+	// narrowing handling
+	wallLevel int
+	// rotation restriction handling
+	rotationLevel    int
+	rotationCooldown int
+	// time limit handling
+	timeLimit int // frames for completing the level, 0 if there is no limit
+	timeLeft  int
+	lostLives int // lives lost when there was no time left or paid for rerolls, for the whole run
+	// darkness handling
+	darknessLevel int
+	// wind handling
+	windLevel     int
+	windFrame     int
+	windDirection int
 	// count score
 	score int
 	// improvements
 	betterRotation      bool
 	canHold             bool
+	ghostLevel          int
+	slowFrames          int // frames left at half speed
 	life                int
 	currentLife         int
 	dead                bool
 	deathAnimationFrame int
 }
 
-func (t *tetris) init(level int, balance balancing, speedLevel int, score int, betterRotation, canHold bool, life, currentLife int) {
-	if level == 0 {
-		t.area = tetrisGrid{}
-		t.currentBlock = getNewBlock(tetrisBlock{id: -1}, tetrisBlock{id: -1})
-		t.currentBlock.setInitialPosition()
-		t.nextBlock = getNewBlock(tetrisBlock{id: -1}, tetrisBlock{id: -1})
-		t.heldBlock = tetrisBlock{id: -1}
-	}
+// empty the board and pick the first pieces, at the start of a run,
+// whatever its first level
+func (t *tetris) newRun() {
+	t.area = tetrisGrid{}
+	// the first pieces are uniformly chosen, maluses are not applied yet
+	t.biasLevel = 0
+	t.lostLives = 0
+	t.piecesSinceI = 0
+	t.pieceCounts = [numStyles]int{}
+	t.currentBlock = t.getNewBlock(tetrisBlock{id: -1}, tetrisBlock{id: -1})
+	t.currentBlock.setInitialPosition()
+	t.nextBlock = t.getNewBlock(tetrisBlock{id: -1}, tetrisBlock{id: -1})
+	t.heldBlock = tetrisBlock{id: -1}
+	t.numPieces = 0
+}
+
+// setup a level, the board and the pieces are kept from the previous level
+func (t *tetris) init(balance balancing, speedLevel int, score int, betterRotation, canHold bool, life, currentLife int) {
 	t.autoDownFrame = 0
-	t.autoDownFrameLimit = gSpeeds[balance.getSpeedLevel(speedLevel)]
+	t.speedLevel = speedLevel
 	t.manualDownFrame = 0
 	t.manualDownFrameLimit = 4
 	t.lrMoveFrame = 0
@@ -90,8 +139,8 @@ func (t *tetris) init(level int, balance balancing, speedLevel int, score int, b
 	t.lrFirstMoveFrameLimit = 15
 	t.manualMoveAllowed = true
 	t.numLines = 0
+	t.tetrises = 0
 	t.dropLenght = 0
-	t.deathLines = balance.getDeathLines()
 	t.toCheck = [2]int{}
 	t.toRemove = [4]bool{}
 	t.toRemoveNum = 0
@@ -100,7 +149,14 @@ func (t *tetris) init(level int, balance balancing, speedLevel int, score int, b
 	t.removeLineAnimationStepNumFrames = 8
 	t.invisibleFrame = 0
 	t.invisibleStep = maxLevelInvisibleBlocks
-	t.invisibleLevel = balance.getInvisibleBlocks()
+	t.mirrorFrame = 0
+	t.mirrored = false
+	t.riseCount = 0
+	t.previewFrame = previewMaxOnFrames
+	t.previewOff = false
+	t.windDirection = 0
+	t.rotationCooldown = 0
+	t.slowFrames = 0
 	t.score = score
 
 	t.betterRotation = betterRotation
@@ -111,9 +167,18 @@ func (t *tetris) init(level int, balance balancing, speedLevel int, score int, b
 	t.deathAnimationFrame = 0
 
 	t.inAnimation = false
+
+	balance.apply(t)
+	t.pickPreview()
+	t.placeWalls()
+	t.timeLeft = t.timeLimit
+	if t.windLevel > 0 {
+		t.windFrame = ruleAt(gRules.WindPeriods, t.windLevel-1)
+	}
 }
 
 func (t *tetris) setUpNext() {
+	t.updateRise()
 	t.lost()
 
 	if t.dead {
@@ -121,10 +186,12 @@ func (t *tetris) setUpNext() {
 		return
 	}
 
-	futureBlock := getNewBlock(t.currentBlock, t.nextBlock)
+	futureBlock := t.getNewBlock(t.currentBlock, t.nextBlock)
 	t.currentBlock = t.nextBlock
 	t.currentBlock.setInitialPosition()
 	t.nextBlock = futureBlock
+	t.pickPreview()
+	t.numPieces++
 
 	t.manualMoveAllowed = false
 
@@ -134,6 +201,10 @@ func (t *tetris) setUpNext() {
 
 func (t *tetris) update(moveDownRequest, moveLeftRequest, moveRightRequest, holdRequest, rotateLeft, rotateRight bool, level int) (playSounds [assets.NumSounds]bool) {
 
+	if gDebug {
+		defer t.assertInvariants()
+	}
+
 	if t.dead {
 		playSounds[assets.SoundDeathID] = t.deathAnimationFrame == 0
 		t.deathAnimationFrame++
@@ -161,6 +232,7 @@ func (t *tetris) update(moveDownRequest, moveLeftRequest, moveRightRequest, hold
 				t.score += 300 * (level + 1)
 			case 4:
 				t.score += 1200 * (level + 1)
+				t.tetrises++
 			}
 			t.numLines += t.toRemoveNum
 		}
@@ -173,7 +245,13 @@ func (t *tetris) update(moveDownRequest, moveLeftRequest, moveRightRequest, hold
 
 		// lines removal animation and effects
 		playSounds[assets.SoundLinesFallingID] = true
+		before := t.area
 		t.removeLines()
+		if gDebug {
+			if err := checkRemoval(before, t.area, t.toRemoveNum); err != nil {
+				panic(fmt.Sprintf("%v\nbefore removal:\n%s", err, gridToText(before)))
+			}
+		}
 
 		t.toRemove = [4]bool{}
 		t.toRemoveNum = 0
@@ -185,12 +263,19 @@ func (t *tetris) update(moveDownRequest, moveLeftRequest, moveRightRequest, hold
 		return
 	}
 
+	// the time limit is paused during lines removal
+	playSounds[assets.SoundMenuNoID] = t.updateTime()
+	if t.dead {
+		return
+	}
+
 	if t.canHold && holdRequest {
 		if canReplace(t.currentBlock.x, t.currentBlock.y, t.heldBlock, t.nextBlock, t.area) {
 			t.heldBlock, t.currentBlock = t.currentBlock, t.heldBlock
 			if t.currentBlock.id < 0 {
 				t.currentBlock = t.nextBlock
-				t.nextBlock = getNewBlock(t.heldBlock, t.currentBlock)
+				t.nextBlock = t.getNewBlock(t.heldBlock, t.currentBlock)
+				t.pickPreview()
 			}
 			t.currentBlock.x = t.heldBlock.x
 			t.currentBlock.y = t.heldBlock.y
@@ -199,7 +284,13 @@ func (t *tetris) update(moveDownRequest, moveLeftRequest, moveRightRequest, hold
 		}
 	}
 
-	t.invisibleFrame++
+	if t.slowFrames > 0 {
+		t.slowFrames--
+	}
+
+	if !t.slowSkip() {
+		t.invisibleFrame++
+	}
 	if t.invisibleFrame >= gInvisibleNumFrames {
 		t.invisibleStep--
 		t.invisibleFrame = 0
@@ -208,15 +299,11 @@ func (t *tetris) update(moveDownRequest, moveLeftRequest, moveRightRequest, hold
 		}
 	}
 
-	effectiveRotation := false
+	t.updateMirror()
+	t.updatePreview()
+	playSounds[assets.SoundLeftRightID] = t.updateWind()
 
-	if rotateLeft && !rotateRight {
-		effectiveRotation = t.currentBlock.rotateLeft(t.area)
-	}
-
-	if rotateRight && !rotateLeft {
-		effectiveRotation = t.currentBlock.rotateRight(t.area)
-	}
+	effectiveRotation := t.rotate(rotateLeft, rotateRight)
 
 	playSounds[assets.SoundRotationID] = effectiveRotation
 	if effectiveRotation && t.betterRotation {
@@ -259,7 +346,9 @@ func (t *tetris) update(moveDownRequest, moveLeftRequest, moveRightRequest, hold
 
 	// automatic down movement of blocks handling
 	autoDown := false
-	t.autoDownFrame++
+	if !t.slowSkip() {
+		t.autoDownFrame++
+	}
 
 	if t.autoDownFrame >= t.autoDownFrameLimit {
 		autoDown = true
@@ -289,7 +378,9 @@ func (t *tetris) update(moveDownRequest, moveLeftRequest, moveRightRequest, hold
 
 	// update position according to movements requests
 	var stuck bool
-	stuck, playSounds[assets.SoundLeftRightID] = t.currentBlock.updatePosition(xMove, autoDown || manualDown, t.area)
+	var lrMoved bool
+	stuck, lrMoved = t.currentBlock.updatePosition(xMove, autoDown || manualDown, t.area)
+	playSounds[assets.SoundLeftRightID] = playSounds[assets.SoundLeftRightID] || lrMoved
 	if stuck {
 		playSounds[assets.SoundTouchGroundID] = true
 
@@ -312,6 +403,13 @@ func (t *tetris) update(moveDownRequest, moveLeftRequest, moveRightRequest, hold
 	return
 }
 
+// panic with a dump of the board if the game is not consistent
+func (t tetris) assertInvariants() {
+	if err := t.checkInvariants(); err != nil {
+		panic(fmt.Sprintf("%v\n%s", err, t.toText(fog{})))
+	}
+}
+
 // check if the lines in toCheck are complete
 // if so, remove them and update the grid
 func (t tetris) checkLines() (toRemoveNum int, firstAvailable int, toRemove [4]bool) {
@@ -319,7 +417,8 @@ func (t tetris) checkLines() (toRemoveNum int, firstAvailable int, toRemove [4]b
 	count := -1
 	firstAvailable = t.toCheck[0] - 1
 
-	// get the lines that will disapear
+	// get the lines that will disapear, walls are never
+	// empty so only the other columns need to be filled
 CheckLoop:
 	for l := t.toCheck[0]; l <= t.toCheck[1]; l++ {
 		count++
@@ -349,7 +448,7 @@ func (t *tetris) removeLines() {
 				t.firstAvailable--
 			}
 		} else {
-			t.area[y] = tetrisLine{}
+			t.area[y] = t.emptyLine()
 		}
 	}
 
@@ -359,7 +458,7 @@ func (t *tetris) removeLines() {
 			t.area[y] = t.area[t.firstAvailable]
 			t.firstAvailable--
 		} else {
-			t.area[y] = tetrisLine{}
+			t.area[y] = t.emptyLine()
 		}
 	}
 
@@ -368,10 +467,10 @@ func (t *tetris) removeLines() {
 // check if there is anything in the above area
 // which would mean that the game is lost
 func (t *tetris) lost() {
-	t.currentLife = t.life
+	t.currentLife = t.life - t.lostLives
 	for _, line := range t.area[:gInvisibleLines+t.deathLines] {
 		for _, v := range line {
-			if v != 0 {
+			if v != noStyle && v != wallStyle {
 				t.currentLife--
 				if t.currentLife < 0 {
 					t.dead = true
@@ -382,10 +481,12 @@ func (t *tetris) lost() {
 	}
 }
 
-func getNewBlock(current, next tetrisBlock) (block tetrisBlock) {
+// weights gives the chances of each piece (in the order of the switch
+// below), the choice is uniform if it is nil
+func getNewBlock(current, next tetrisBlock, weights []int) (block tetrisBlock) {
 
 	getRandomBlock := func() tetrisBlock {
-		switch rand.Intn(7) {
+		switch weightedChoice(weights, 7) {
 		case 0:
 			return getIBlock()
 		case 1:
@@ -462,11 +563,15 @@ func (t tetris) drawLife(screen *ebiten.Image, gray uint8) {
 func (t tetris) draw(screen *ebiten.Image, gray uint8) {
 
 	t.drawLife(screen, gray)
+	t.drawRise(screen, gray)
+	t.drawBias(screen, gray)
+	t.drawRotation(screen, gray)
+	t.drawTime(screen, gray)
 
 	xNextOrigin := gPlayAreaSide + gPlayAreaWidth + gPlayAreaSide + gInfoLeftSide + gNextMargin
 	yNextOrigin := gInfoTop + gInfoSmallBoxHeight + gScoreToLevel + gInfoBoxHeight + gLevelToLines + gInfoBoxHeight + gLinesToNext + gNextMargin
 
-	t.nextBlock.draw(screen, gray, xNextOrigin, yNextOrigin, 1)
+	t.drawNext(screen, gray, xNextOrigin, yNextOrigin)
 
 	if t.canHold {
 		t.drawHold(screen, gray)
@@ -475,8 +580,10 @@ func (t tetris) draw(screen *ebiten.Image, gray uint8) {
 	xOrigin := gPlayAreaSide
 	yOrigin := gSquareSideSize * -gInvisibleLines
 
+	t.drawGhost(screen, gray, xOrigin, yOrigin)
+
 	if t.removeLineAnimationStep == 0 {
-		if t.invisibleStep > t.invisibleLevel || t.currentBlock.y < gInvisibleLines {
+		if t.currentBlockVisible() {
 			t.currentBlock.draw(screen, gray, xOrigin, yOrigin, 1)
 		}
 	}
@@ -504,4 +611,11 @@ func (t tetris) draw(screen *ebiten.Image, gray uint8) {
 		}
 	}
 
+	t.drawGhostLines(screen, gray, xOrigin, yOrigin)
+
+}
+
+// check if the current piece is not hidden by the invisible blocks malus
+func (t tetris) currentBlockVisible() bool {
+	return t.invisibleStep > t.invisibleLevel || t.currentBlock.y < gInvisibleLines
 }
@@ -105,24 +105,37 @@ func (b *tetrisBlock) updatePosition(rlMove int, dMove bool, grid tetrisGrid) (s
 	return
 }
 
-func (t *tetrisBlock) rotateLeft(grid tetrisGrid) bool {
-	t.r = (t.r + 3) % 4
+// states is the number of rotation states that can be used (4 or 2)
+func (t *tetrisBlock) rotateLeft(grid tetrisGrid, states int) bool {
+	r := t.r
+	t.r = (t.r + states - 1) % states
 	if !t.isInValidPosition(grid) {
-		t.r = (t.r + 1) % 4
+		t.r = r
 		return false
 	}
 	return true
 }
 
-func (t *tetrisBlock) rotateRight(grid tetrisGrid) bool {
-	t.r = (t.r + 1) % 4
+func (t *tetrisBlock) rotateRight(grid tetrisGrid, states int) bool {
+	r := t.r
+	t.r = (t.r + 1) % states
 	if !t.isInValidPosition(grid) {
-		t.r = (t.r + 3) % 4
+		t.r = r
 		return false
 	}
 	return true
 }
 
+// get the block as it would be after falling as low as possible
+func (t tetrisBlock) landingPosition(grid tetrisGrid) tetrisBlock {
+	if t.id < 0 {
+		return t
+	}
+	for !t.moveDown(grid) {
+	}
+	return t
+}
+
 func (t tetrisBlock) writeInGrid(grid *tetrisGrid) (toCheck [2]int) {
 
 	yMin := len(grid)
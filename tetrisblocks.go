@@ -29,8 +29,32 @@ const (
 	tBlockStyle
 	zBlockStyle
 	breakStyle
+	garbageStyle // rows pushed by the rising floor malus
+	wallStyle    // columns taken by the narrowing malus
+	numStyles
 )
 
+// get a block from its style
+func getBlockOfStyle(style int) (block tetrisBlock, ok bool) {
+	switch style {
+	case iBlockStyle:
+		return getIBlock(), true
+	case oBlockStyle:
+		return getOBlock(), true
+	case jBlockStyle:
+		return getJBlock(), true
+	case lBlockStyle:
+		return getLBlock(), true
+	case sBlockStyle:
+		return getSBlock(), true
+	case tBlockStyle:
+		return getTBlock(), true
+	case zBlockStyle:
+		return getZBlock(), true
+	}
+	return tetrisBlock{id: -1}, false
+}
+
 func getIBlock() tetrisBlock {
 	return tetrisBlock{
 		id:    2,
@@ -0,0 +1,217 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Text format for boards, one row per line:
+//
+//	^ |..........|
+//	! |...@@@....|
+//	  |....@+....|
+//	 ~|LLL..ZZ.II|
+//
+// The first character of a row gives its zone ('^' for the rows above
+// the visible area, '!' for the danger zone), the second one tells if
+// the row is covered by fog ('~'). Inside the bars, '.' is an empty
+// square, letters are squares left by the corresponding tetromino,
+// 'X' is garbage pushed by the rising floor, '=' is a wall, '#' is a
+// square being broken, '@' is the active piece and '+' is its ghost
+// (where it would land).
+
+const (
+	textEmpty  rune = '.'
+	textActive rune = '@'
+	textGhost  rune = '+'
+	textSpawn  rune = '^'
+	textDanger rune = '!'
+	textFog    rune = '~'
+	textBorder rune = '|'
+)
+
+// runes used for each block style
+var gStyleRunes = []rune{
+	noStyle:      textEmpty,
+	iBlockStyle:  'I',
+	oBlockStyle:  'O',
+	jBlockStyle:  'J',
+	lBlockStyle:  'L',
+	sBlockStyle:  'S',
+	tBlockStyle:  'T',
+	zBlockStyle:  'Z',
+	breakStyle:   '#',
+	garbageStyle: 'X',
+	wallStyle:    '=',
+}
+
+func styleToRune(style int) rune {
+	if style >= 0 && style < len(gStyleRunes) {
+		return gStyleRunes[style]
+	}
+	return '?'
+}
+
+func runeToStyle(r rune) (style int, ok bool) {
+	if r == textActive || r == textGhost {
+		return noStyle, true
+	}
+	for style, sr := range gStyleRunes {
+		if sr == r {
+			return style, true
+		}
+	}
+	return noStyle, false
+}
+
+// name of a block for text output
+func blockToText(b tetrisBlock) string {
+	if b.id < 0 {
+		return "-"
+	}
+	return string(styleToRune(b.style))
+}
+
+// write a grid as text, without any zone information
+func gridToText(grid tetrisGrid) string {
+	var sb strings.Builder
+	for _, line := range grid {
+		sb.WriteString("  ")
+		writeTextLine(&sb, line, nil)
+	}
+	return sb.String()
+}
+
+// write one line of the grid, overlay gives the runes
+// that should replace the content of some squares
+func writeTextLine(sb *strings.Builder, line tetrisLine, overlay map[int]rune) {
+	sb.WriteRune(textBorder)
+	for x, style := range line {
+		if r, ok := overlay[x]; ok {
+			sb.WriteRune(r)
+			continue
+		}
+		sb.WriteRune(styleToRune(style))
+	}
+	sb.WriteRune(textBorder)
+	sb.WriteRune('\n')
+}
+
+// squares of a block as absolute grid positions
+func blockSquares(b tetrisBlock) (squares [][2]int) {
+	if b.id < 0 {
+		return
+	}
+	for yRel, line := range b.states[b.r] {
+		for xRel, square := range line {
+			if square {
+				squares = append(squares, [2]int{b.x + xRel, b.y + yRel})
+			}
+		}
+	}
+	return
+}
+
+// write the whole state of a tetris game as text, fog gives
+// the rows hidden at the bottom of the play area
+func (t tetris) toText(f fog) string {
+
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "score %d lines %d death %d life %d/%d\n", t.score, t.numLines, t.deathLines, t.currentLife, t.life)
+	fmt.Fprintf(&sb, "next %s hold %s\n", blockToText(t.nextBlock), blockToText(t.heldBlock))
+
+	overlay := make(map[[2]int]rune)
+	if !t.dead && t.removeLineAnimationStep == 0 {
+		for _, pos := range blockSquares(t.currentBlock.landingPosition(t.area)) {
+			overlay[pos] = textGhost
+		}
+		for _, pos := range blockSquares(t.currentBlock) {
+			overlay[pos] = textActive
+		}
+	}
+
+	firstFog := len(t.area) - f.currentHiddenLines
+
+	for y, line := range t.area {
+		zone := ' '
+		if y < gInvisibleLines {
+			zone = textSpawn
+		} else if y < gInvisibleLines+t.deathLines {
+			zone = textDanger
+		}
+		fogged := ' '
+		if y >= firstFog && y >= gInvisibleLines {
+			fogged = textFog
+		}
+		sb.WriteRune(zone)
+		sb.WriteRune(fogged)
+
+		lineOverlay := make(map[int]rune)
+		for x := range line {
+			if r, ok := overlay[[2]int{x, y}]; ok {
+				lineOverlay[x] = r
+			}
+		}
+		writeTextLine(&sb, line, lineOverlay)
+	}
+
+	return sb.String()
+}
+
+// read a grid from text, only the lines between bars are
+// considered, and they are aligned with the bottom of the grid
+// when there are less of them than the height of the grid
+func textToGrid(text string) (grid tetrisGrid, err error) {
+
+	lines := make([]tetrisLine, 0, len(grid))
+
+	for num, row := range strings.Split(text, "\n") {
+		start := strings.IndexRune(row, textBorder)
+		end := strings.LastIndex(row, string(textBorder))
+		if start < 0 || end <= start {
+			continue
+		}
+
+		cells := []rune(row[start+1 : end])
+		if len(cells) != gPlayAreaWidthInBlocks {
+			return grid, fmt.Errorf("line %d: %d squares instead of %d", num+1, len(cells), gPlayAreaWidthInBlocks)
+		}
+
+		var line tetrisLine
+		for x, r := range cells {
+			style, ok := runeToStyle(r)
+			if !ok {
+				return grid, fmt.Errorf("line %d: unknown square %q", num+1, r)
+			}
+			line[x] = style
+		}
+
+		if len(lines) >= len(grid) {
+			return grid, fmt.Errorf("line %d: more than %d lines", num+1, len(grid))
+		}
+		lines = append(lines, line)
+	}
+
+	copy(grid[len(grid)-len(lines):], lines)
+
+	return grid, nil
+}
@@ -0,0 +1,238 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTextGridRoundTrip(t *testing.T) {
+	var grid tetrisGrid
+	styles := []int{noStyle, iBlockStyle, oBlockStyle, jBlockStyle, lBlockStyle, sBlockStyle, tBlockStyle, zBlockStyle, breakStyle, garbageStyle, wallStyle}
+	for y := range grid {
+		for x := range grid[y] {
+			grid[y][x] = styles[(x+y*3)%len(styles)]
+		}
+	}
+
+	got, err := textToGrid(gridToText(grid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != grid {
+		t.Errorf("round trip changed the grid:\n%s\ninstead of\n%s", gridToText(got), gridToText(grid))
+	}
+}
+
+func TestTextToGridErrors(t *testing.T) {
+	for _, text := range []string{
+		"|.........|",
+		"|....?.....|",
+	} {
+		if _, err := textToGrid(text); err == nil {
+			t.Errorf("no error for %q", text)
+		}
+	}
+}
+
+func TestRemoveLines(t *testing.T) {
+	tests := []struct {
+		name      string
+		wallLevel int
+		check     [2]int // rows touched by the last piece, from the bottom
+		before    string
+		after     string
+		removed   int
+	}{
+		{
+			name:  "one line",
+			check: [2]int{1, 0},
+			before: `
+				|..........|
+				|....T.....|
+				|IIIITTT.OO|
+				|IIIITTTJOO|`,
+			after: `
+				|..........|
+				|..........|
+				|....T.....|
+				|IIIITTT.OO|`,
+			removed: 1,
+		},
+		{
+			name:  "lines apart",
+			check: [2]int{3, 0},
+			before: `
+				|.....S....|
+				|LLLLLSSIII|
+				|L..ZZ.S...|
+				|LLLLLZZIII|
+				|OO.JJJJIII|`,
+			after: `
+				|..........|
+				|..........|
+				|.....S....|
+				|L..ZZ.S...|
+				|OO.JJJJIII|`,
+			removed: 2,
+		},
+		{
+			name:  "tetris over garbage",
+			check: [2]int{4, 1},
+			before: `
+				|..........|
+				|IIIIOOJJJI|
+				|IIIIOOJTTI|
+				|LLLLTTTZZI|
+				|SSSSZZZZLI|
+				|XXXX.XXXXX|`,
+			after: `
+				|..........|
+				|..........|
+				|..........|
+				|..........|
+				|..........|
+				|XXXX.XXXXX|`,
+			removed: 4,
+		},
+		{
+			name:      "walls",
+			wallLevel: 2,
+			check:     [2]int{1, 0},
+			before: `
+				|=........=|
+				|=IIIIOOJJ=|
+				|=.TTTLL..=|`,
+			after: `
+				|=........=|
+				|=........=|
+				|=.TTTLL..=|`,
+			removed: 1,
+		},
+	}
+
+	for _, test := range tests {
+		before, err := textToGrid(test.before)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		after, err := textToGrid(test.after)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		// rows above the fixtures are empty lines, with their walls
+		var tt tetris
+		tt.wallLevel = test.wallLevel
+		for y := 0; y < len(before)-textRows(test.before); y++ {
+			before[y] = tt.emptyLine()
+		}
+		for y := 0; y < len(after)-textRows(test.after); y++ {
+			after[y] = tt.emptyLine()
+		}
+
+		tt.area = before
+		tt.toCheck = [2]int{len(before) - 1 - test.check[0], len(before) - 1 - test.check[1]}
+		tt.toRemoveNum, tt.firstAvailable, tt.toRemove = tt.checkLines()
+		if tt.toRemoveNum != test.removed {
+			t.Errorf("%s: %d lines complete instead of %d", test.name, tt.toRemoveNum, test.removed)
+		}
+		tt.removeLines()
+		if tt.area != after {
+			t.Errorf("%s: got\n%s\ninstead of\n%s", test.name, gridToText(tt.area), gridToText(after))
+		}
+	}
+}
+
+// number of rows of a board given as text
+func textRows(text string) int {
+	return strings.Count(text, string(textBorder)) / 2
+}
+
+func TestPlaceWalls(t *testing.T) {
+	tests := []struct {
+		name      string
+		wallLevel int
+		before    string
+		after     string
+	}{
+		{
+			name:      "line completed by a wall",
+			wallLevel: 1,
+			before: `
+				|....T.....|
+				|IIIITTTOO.|
+				|LLLJJJ.ZZ.|`,
+			after: `
+				|.........=|
+				|....T....=|
+				|LLLJJJ.ZZ=|`,
+		},
+		{
+			name:      "line emptied by a wall",
+			wallLevel: 1,
+			before: `
+				|.......OOI|
+				|.......OOI|
+				|.........I|
+				|.........I|
+				|LLLJJJ.ZZ.|`,
+			after: `
+				|.........=|
+				|.........=|
+				|.......OO=|
+				|.......OO=|
+				|LLLJJJ.ZZ=|`,
+		},
+		{
+			name:      "old walls removed",
+			wallLevel: 1,
+			before: `
+				|=........=|
+				|=.TTTLL..=|`,
+			after: `
+				|.........=|
+				|..TTTLL..=|`,
+		},
+	}
+
+	for _, test := range tests {
+		before, err := textToGrid(test.before)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		after, err := textToGrid(test.after)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		tt := tetris{area: before, wallLevel: test.wallLevel}
+		for y := 0; y < len(after)-textRows(test.after); y++ {
+			after[y] = tt.emptyLine()
+		}
+
+		tt.placeWalls()
+		if tt.area != after {
+			t.Errorf("%s: got\n%s\ninstead of\n%s", test.name, gridToText(tt.area), gridToText(after))
+		}
+		if err := checkGrid(tt.area); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import "github.com/hajimehoshi/ebiten/v2"
+
+const (
+	timeFramesPerSecond int = 60
+	timeHurryFrames     int = 10 * timeFramesPerSecond // the timer blinks when less time is left
+	timeBlinkFrames     int = 15
+
+	// position of the timer, below the lines counter
+	timeXFromLeftSide int = gPlayAreaSide + gPlayAreaWidth + gPlayAreaSide + gInfoLeftSide + 2*gMultFactor + 3*gSquareSideSize/2
+	timeYFromTop      int = gYLinesFromTop + 13*gMultFactor
+)
+
+// count down the time left for the level, when there is no more
+// time the player loses a life, or the game if there is no life left
+func (t *tetris) updateTime() (timeout bool) {
+	if t.timeLimit <= 0 {
+		return
+	}
+
+	t.timeLeft--
+	if t.timeLeft > 0 {
+		return
+	}
+
+	t.timeLeft = t.timeLimit
+	if t.currentLife <= 0 {
+		t.dead = true
+	} else {
+		t.lostLives++
+		t.lost()
+	}
+	t.inAnimation = t.dead
+
+	return true
+}
+
+// seconds left, below the lines counter
+func (t tetris) drawTime(screen *ebiten.Image, gray uint8) {
+	if t.timeLimit <= 0 {
+		return
+	}
+
+	if t.timeLeft < timeHurryFrames && (t.timeLeft/timeBlinkFrames)%2 == 1 {
+		return
+	}
+
+	seconds := (t.timeLeft + timeFramesPerSecond - 1) / timeFramesPerSecond
+	drawScaledNumberAt(screen, gray, timeXFromLeftSide, timeYFromTop, seconds, -1, 0.5)
+}
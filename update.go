@@ -18,11 +18,7 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 */
 package main
 
-import (
-	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
-	"github.com/loig/ebitenginegamejam2024/assets"
-)
+import "github.com/loig/ebitenginegamejam2024/assets"
 
 func (g *game) Update() (err error) {
 
@@ -30,23 +26,33 @@ func (g *game) Update() (err error) {
 	g.audio.PlaySounds()
 	g.audio.NextSounds = [assets.NumSounds]bool{}
 
+	if g.devMode && g.updateDevConsole() {
+		return nil
+	}
+
+	g.updateInput()
+
 	if g.state != stateControls && g.state != stateWon {
 		g.audio.UpdateMusic(0.7)
 	}
 
-	betterRotation := g.improv.levels[improveResetAutoDown] > 0
-	canHold := g.improv.levels[improveHold] > 0
-	life := g.improv.levels[improveLife]*2 - 1
+	g.updateState()
+
+	return nil
+}
+
+// update the game according to its current state and g.input
+func (g *game) updateState() {
 
 	switch g.state {
 	case stateControls:
-		if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+		if g.input.isJustPressed(inputEnter) {
 			g.audio.NextSounds[assets.SoundMenuConfirmID] = true
 			g.state = stateTitle
 			g.titleFrame = 0
 		}
 	case stateCredits:
-		if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+		if g.input.isJustPressed(inputEnter) {
 			g.audio.NextSounds[assets.SoundMenuConfirmID] = true
 			g.state = stateTitle
 			g.titleFrame = 0
@@ -59,10 +65,7 @@ func (g *game) Update() (err error) {
 		if g.updateStateTitle() {
 			if g.titleSelect == 0 {
 				g.firstPlay = false
-				g.state = statePlay
-				g.balance = newBalance(g.numChoices)
-				g.currentPlay.init(g.level, g.balance, g.level, 0, betterRotation, canHold, life, life)
-				g.fog.reset(g.balance.getHiddenLines(), g.improv.levels[improveHideMove])
+				g.startRun(gRandom.Int63())
 			} else {
 				g.state = stateCredits
 			}
@@ -71,32 +74,42 @@ func (g *game) Update() (err error) {
 		if g.updateStatePlay() {
 			g.state = stateLost
 			g.money.addScore(g.currentPlay.score)
+			g.stopRecording()
+			g.endRun(false)
 		}
-		if !g.currentPlay.inAnimation && g.currentPlay.numLines >= g.balance.getGoalLines() {
-			if g.level+1 >= g.goalLevel {
+		if !g.currentPlay.inAnimation && g.currentPlay.numLines >= g.currentPlay.goalLines {
+			if goal := g.runGoalLevel(); goal > 0 && g.level+1 >= goal {
 				g.state = stateWon
+				g.winFrame = 0
 				g.audio.NextSounds[assets.SoundBuyID] = true
 				g.audio.StopMusic()
-				return nil
+				g.stopRecording()
+				g.endRun(true)
+				return
+			}
+			if g.mode == modePractice {
+				g.level++
+				g.startLevel(g.currentPlay.score, g.currentPlay.currentLife)
+				return
 			}
 			g.state = stateBalance
-			g.balance.getChoice()
+			g.endLevelBonuses()
+			g.cleanseByTetris()
+			g.offerMaluses()
 		}
 	case stateBalance:
-		finished, playSounds := g.balance.update()
-		g.audio.NextSounds = playSounds
-		if finished {
+		if g.updateStateBalance() {
+			g.takeBonus(g.balance.taken)
 			g.state = statePlay
 			g.level++
-			g.currentPlay.init(g.level, g.balance, g.level, g.currentPlay.score, betterRotation, canHold, life, g.currentPlay.currentLife)
-			g.fog.reset(g.balance.getHiddenLines(), g.improv.levels[improveHideMove])
+			g.startLevel(g.currentPlay.score, g.currentPlay.currentLife)
 		}
 	case stateLost:
-		finished, playSounds := g.money.update()
+		finished, playSounds := g.money.update(g.input)
 		g.audio.NextSounds = playSounds
 		if finished {
 			g.state = stateImprove
-			g.level = 0
+			g.level = g.firstLevel
 			g.improv.reset()
 		}
 		g.currentPlay.score = g.money.score
@@ -114,36 +127,130 @@ func (g *game) Update() (err error) {
 		if g.winFrame == 16 {
 			g.audio.NextSounds[assets.SoundRocketID] = true
 		}
+		// the score of a win is paid like the one of a lost run
+		if g.input.isJustPressed(inputEnter) {
+			g.audio.NextSounds[assets.SoundMenuConfirmID] = true
+			g.state = stateLost
+			g.money.addScore(g.currentPlay.score)
+		}
 	}
+}
 
-	return nil
+// number of lives given by the improvements
+func (g game) improvedLife() int {
+	return g.improv.levels[improveLife]*2 - 1
+}
+
+// number of lives given by the improvements, minus the ones of the tier,
+// the tier hearts that cannot be removed are danger lines instead (see setTier)
+func (g game) maxLife() int {
+	return max(g.improvedLife()-g.tierRules().Hearts, -1)
+}
+
+// setup the play for the current level
+func (g *game) startLevel(score, currentLife int) {
+	life := g.maxLife()
+	if g.bonusHearts > 0 {
+		life = max(life, 0) + g.bonusHearts
+	}
+	currentLife = min(currentLife+g.bonusHearts, life)
+	// hold and rotation are set by the improvements
+	g.currentPlay.init(g.balance, g.level, score, false, false, life, currentLife)
+	if g.bonusPreview && g.currentPlay.previewLevel > 0 {
+		g.currentPlay.previewLevel--
+		g.currentPlay.pickPreview()
+	}
+	g.applyImprovements()
+	g.cleanseTarget = -1
+	if g.mode != modePractice {
+		// the malus levels never change in practice mode
+		g.cleanseTarget = g.balance.randomActive()
+	}
 }
 
 func (g *game) updateStateTitle() (end bool) {
-	if inpututil.IsKeyJustPressed(ebiten.KeyRight) || inpututil.IsKeyJustPressed(ebiten.KeyDown) || inpututil.IsKeyJustPressed(ebiten.KeyLeft) || inpututil.IsKeyJustPressed(ebiten.KeyUp) {
+	horizontal := g.input.isJustPressed(inputRight) || g.input.isJustPressed(inputLeft)
+	vertical := g.input.isJustPressed(inputDown) || g.input.isJustPressed(inputUp)
+
+	// left and right choose the tier once one is unlocked
+	if horizontal && g.titleSelect == 0 && g.progress.MaxTier > 0 {
+		step := 1
+		if g.input.isJustPressed(inputLeft) {
+			step = -1
+		}
+		if g.selectTier(step) {
+			g.audio.NextSounds[assets.SoundMenuMoveID] = true
+		} else {
+			g.audio.NextSounds[assets.SoundMenuNoID] = true
+		}
+		horizontal = false
+	}
+
+	if horizontal || vertical {
 		g.audio.NextSounds[assets.SoundMenuMoveID] = true
 		g.titleSelect = (g.titleSelect + 1) % 2
 	}
 
-	end = inpututil.IsKeyJustPressed(ebiten.KeyEnter)
+	end = g.input.isJustPressed(inputEnter)
 	g.audio.NextSounds[assets.SoundMenuConfirmID] = end
 	return
 }
 
+// choose a malus, with the reroll and the skip token
+func (g *game) updateStateBalance() (finished bool) {
+	input := g.input
+
+	if !g.balance.busy() && input.isJustPressed(inputUp) {
+		if g.reroll() {
+			g.audio.NextSounds[assets.SoundBuyID] = true
+		} else {
+			g.audio.NextSounds[assets.SoundMenuNoID] = true
+		}
+		return false
+	}
+
+	if !g.balance.busy() && input.isJustPressed(inputEnter) && g.balance.skipSelected() && !g.paySkip() {
+		g.audio.NextSounds[assets.SoundMenuNoID] = true
+		return false
+	}
+
+	if !g.balance.busy() && input.isJustPressed(inputEnter) && g.balance.cleanseSelected() && g.balance.cleanseForced {
+		g.improv.levels[improveCleanse]--
+	}
+
+	finished, g.audio.NextSounds = g.balance.update(input)
+	return
+}
+
 func (g *game) updateStatePlay() bool {
+	input := g.input
+	if g.currentPlay.mirrored {
+		input = input.mirrored()
+	}
+
 	sounds := g.currentPlay.update(
-		ebiten.IsKeyPressed(ebiten.KeyDown),
-		ebiten.IsKeyPressed(ebiten.KeyLeft),
-		ebiten.IsKeyPressed(ebiten.KeyRight),
-		inpututil.IsKeyJustPressed(ebiten.KeyUp),
-		inpututil.IsKeyJustPressed(ebiten.KeyAlt),
-		inpututil.IsKeyJustPressed(ebiten.KeySpace),
+		input.isPressed(inputDown),
+		input.isPressed(inputLeft),
+		input.isPressed(inputRight),
+		input.isJustPressed(inputUp),
+		input.isJustPressed(inputAlt),
+		input.isJustPressed(inputSpace),
 		g.level,
 	)
 
 	g.audio.NextSounds = sounds
 
-	g.fog.update()
+	if input.isJustPressed(inputSlow) {
+		if g.useSlow() {
+			g.audio.NextSounds[assets.SoundMenuConfirmID] = true
+		} else {
+			g.audio.NextSounds[assets.SoundMenuNoID] = true
+		}
+	}
+
+	if !g.currentPlay.slowSkip() {
+		g.fog.update()
+	}
 
 	return g.currentPlay.dead && !g.currentPlay.inAnimation
 }
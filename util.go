@@ -28,30 +28,38 @@ import (
 
 // draw a number right alligned in a rectangle which top right is given by (x, y) in pixels
 func drawNumberAt(screen *ebiten.Image, gray uint8, x, y int, num int, over int) {
+	drawScaledNumberAt(screen, gray, x, y, num, over, 1)
+}
+
+// same as drawNumberAt, with digits scaled by the given factor
+func drawScaledNumberAt(screen *ebiten.Image, gray uint8, x, y int, num int, over int, scaling float64) {
 
 	if num < 0 {
 		num = 0
 	}
 
+	step := float64(gSquareSideSize) * scaling
+
 	options := ebiten.DrawImageOptions{}
 
 	options.ColorScale.ScaleWithColor(color.Gray{gray})
 
+	options.GeoM.Scale(scaling, scaling)
 	options.GeoM.Translate(float64(x), float64(y))
 
 	if over > 0 {
 		if over >= 10 {
-			options.GeoM.Translate(float64(gSquareSideSize), 0)
+			options.GeoM.Translate(step, 0)
 		}
 		for over > 0 {
 			digit := over % 10
 			over = over / 10
 
-			options.GeoM.Translate(float64(-gSquareSideSize), float64(0))
+			options.GeoM.Translate(-step, float64(0))
 			screen.DrawImage(assets.ImageDigits.SubImage(image.Rect(digit*gSquareSideSize, 0, (digit+1)*gSquareSideSize, gSquareSideSize)).(*ebiten.Image), &options)
 		}
 
-		options.GeoM.Translate(float64(-gSquareSideSize), float64(0))
+		options.GeoM.Translate(-step, float64(0))
 		screen.DrawImage(assets.ImageDigits.SubImage(image.Rect(10*gSquareSideSize, 0, 11*gSquareSideSize, gSquareSideSize)).(*ebiten.Image), &options)
 	}
 
@@ -61,14 +69,36 @@ func drawNumberAt(screen *ebiten.Image, gray uint8, x, y int, num int, over int)
 		digit := num % 10
 		num = num / 10
 
-		options.GeoM.Translate(float64(-gSquareSideSize), float64(0))
+		options.GeoM.Translate(-step, float64(0))
 		screen.DrawImage(assets.ImageDigits.SubImage(image.Rect(digit*gSquareSideSize, 0, (digit+1)*gSquareSideSize, gSquareSideSize)).(*ebiten.Image), &options)
 	}
 
 }
 
-// remove one element from a slice of int
-func removeElement(t []int, pos int) []int {
-	t[pos], t[len(t)-1] = t[len(t)-1], t[pos]
-	return t[:len(t)-1]
+// choose a number between 0 and n-1 with the given weights,
+// the choice is uniform if weights is nil
+func weightedChoice(weights []int, n int) int {
+	if weights == nil {
+		return gRandom.Intn(n)
+	}
+	total := 0
+	for _, w := range weights {
+		total += w
+	}
+	choice := gRandom.Intn(total)
+	for i, w := range weights {
+		if choice < w {
+			return i
+		}
+		choice -= w
+	}
+	return n - 1
+}
+
+// darken a color like the images drawn with a given gray
+func grayColor(c color.RGBA, gray uint8) color.RGBA {
+	c.R = uint8(int(c.R) * int(gray) / 255)
+	c.G = uint8(int(c.G) * int(gray) / 255)
+	c.B = uint8(int(c.B) * int(gray) / 255)
+	return c
 }
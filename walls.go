@@ -0,0 +1,84 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+// columns of the play area replaced by walls,
+// for each level of the narrowing malus
+var gWallColumns = [maxLevelWalls + 1][]int{
+	{},
+	{gPlayAreaWidthInBlocks - 1},
+	{0, gPlayAreaWidthInBlocks - 1},
+	{0, gPlayAreaWidthInBlocks - 2, gPlayAreaWidthInBlocks - 1},
+}
+
+// check if a column is a wall
+func (t tetris) isWall(x int) bool {
+	for _, column := range gWallColumns[t.wallLevel] {
+		if column == x {
+			return true
+		}
+	}
+	return false
+}
+
+// a line without anything but the walls
+func (t tetris) emptyLine() (line tetrisLine) {
+	for _, column := range gWallColumns[t.wallLevel] {
+		line[column] = wallStyle
+	}
+	return
+}
+
+// put the walls of the current level in the grid and remove the
+// old ones, squares that were in the way of walls are lost, lines
+// completed by walls are removed without any score, and so are the
+// lines left with only walls, so that no empty line is under others
+func (t *tetris) placeWalls() {
+	for y := range t.area {
+		for x := range t.area[y] {
+			if t.isWall(x) {
+				t.area[y][x] = wallStyle
+			} else if t.area[y][x] == wallStyle {
+				t.area[y][x] = noStyle
+			}
+		}
+	}
+
+	to := len(t.area) - 1
+	for from := len(t.area) - 1; from >= 0; from-- {
+		if !isLineComplete(t.area[from]) && countLineCells(t.area[from]) > 0 {
+			t.area[to] = t.area[from]
+			to--
+		}
+	}
+	for ; to >= 0; to-- {
+		t.area[to] = t.emptyLine()
+	}
+}
+
+// choose a column for a hole, walls excluded
+func (t tetris) randomHole() int {
+	hole := gRandom.Intn(gPlayAreaWidthInBlocks - len(gWallColumns[t.wallLevel]))
+	for x := 0; x <= hole; x++ {
+		if t.isWall(x) {
+			hole++
+		}
+	}
+	return hole
+}
@@ -0,0 +1,92 @@
+/*
+A game for Ebitengine game jam 2024
+
+# Copyright (C) 2024 Loïg Jezequel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	windUpFrames     int     = 60 // a gust is announced one second before
+	windBlinkFrames  int     = 6  // blinking speed of the announcement
+	windChevrons     int     = 3  // number of chevrons of the announcement
+	windChevronSize  float32 = 24 // half height of a chevron in pixels
+	windChevronSpace float32 = 30 // distance between two chevrons in pixels
+	windChevronWidth float32 = 8  // width of the lines of chevrons in pixels
+)
+
+var windColor = color.RGBA{246, 132, 241, 255}
+
+// count the frames to the next gust, choose its direction when it is
+// announced, and push the active piece when it comes
+func (t *tetris) updateWind() (pushed bool) {
+	if t.windLevel <= 0 {
+		return
+	}
+
+	t.windFrame--
+
+	if t.windFrame == windUpFrames {
+		t.windDirection = 1
+		if gRandom.Intn(2) == 0 {
+			t.windDirection = -1
+		}
+	}
+
+	if t.windFrame > 0 {
+		return
+	}
+
+	t.windFrame = ruleAt(gRules.WindPeriods, t.windLevel-1)
+	for i := 0; i < ruleAt(gRules.WindStrengths, t.windLevel-1); i++ {
+		if t.windDirection < 0 {
+			pushed = t.currentBlock.moveLeft(t.area) || pushed
+		} else {
+			pushed = t.currentBlock.moveRight(t.area) || pushed
+		}
+	}
+
+	return
+}
+
+// chevrons on top of the play area pointing
+// where the next gust will push the piece
+func (t tetris) drawWind(screen *ebiten.Image, gray uint8) {
+	if t.windLevel <= 0 || t.windFrame > windUpFrames {
+		return
+	}
+
+	if (t.windFrame/windBlinkFrames)%2 == 1 {
+		return
+	}
+
+	c := grayColor(windColor, gray)
+	xCenter := float32(gPlayAreaSide + gPlayAreaWidth/2)
+	y := float32(gSquareSideSize)
+	dir := float32(t.windDirection)
+
+	for i := 0; i < windChevrons; i++ {
+		x := xCenter + dir*windChevronSpace*float32(i-windChevrons/2)
+		vector.StrokeLine(screen, x-dir*windChevronSize/2, y-windChevronSize, x+dir*windChevronSize/2, y, windChevronWidth, c, false)
+		vector.StrokeLine(screen, x+dir*windChevronSize/2, y, x-dir*windChevronSize/2, y+windChevronSize, windChevronWidth, c, false)
+	}
+}